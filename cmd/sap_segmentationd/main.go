@@ -1,23 +1,26 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 
 	_ "go-test/docs/generated"
 	"go-test/internal/api"
+	"go-test/internal/cache"
+	"go-test/internal/handlers"
+	"go-test/internal/importer"
 	"go-test/internal/logutil"
+	"go-test/internal/outbox"
+	"go-test/internal/progress"
 	"go-test/internal/repository"
 	"go-test/internal/sap"
 	"go-test/internal/storage"
+	"go-test/internal/validation"
+	"go-test/internal/webhook"
 	"go-test/pkg/config"
 )
 
-const (
-	logDir      = "log"
-	logFileName = "segmentation_import.log"
-)
-
 // @title SAP Segmentation API
 // @version 1.0
 // @description API для импорта и доступа к данным сегментации из SAP
@@ -30,19 +33,39 @@ const (
 
 // @host localhost:8080
 // @BasePath /
+
+// @securityDefinitions.apikey AdminToken
+// @in header
+// @name Authorization
+// @description Bearer-токен из ADMIN_TOKEN
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "mock-sap" {
+		runMockSAP(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "webhook-receiver" {
+		runWebhookReceiver(os.Args[2:])
+		return
+	}
+
 	initLogger := log.New(os.Stdout, "INIT: ", log.LstdFlags)
 
-	cfg := config.MustLoad(nil)
+	cfg, err := config.Load()
+	if err != nil {
+		initLogger.Fatalf("failed to load config: %v", err)
+	}
+
+	levels := logutil.NewLevels(cfg)
 
-	logger, err := logutil.SetupLogger(cfg.Env, logDir, logFileName)
+	appLogger, err := logutil.New(cfg, levels)
 	if err != nil {
 		initLogger.Fatalf("failed to setup logger: %v", err)
 	}
+	defer appLogger.Close()
 
-	if err := logutil.CleanupOldLogs(logDir, cfg.Import.LogCleanupMaxAge, logger); err != nil {
-		logger.Error("failed to cleanup old logs", "error", err.Error())
-	}
+	logger := appLogger.Logger
+
+	go appLogger.RunCleanup(context.Background())
 
 	db, err := storage.NewPostgresDB(cfg, logger)
 	if err != nil {
@@ -51,36 +74,60 @@ func main() {
 	}
 	defer db.Close()
 
-	segmentationRepo := repository.NewSegmentationRepository(db)
+	repoLogger := levels.Logger(logger, logutil.ComponentRepository)
+	segmentationRepo := repository.NewSegmentationRepository(db, repoLogger, cfg.Import.DBChunkSize, cfg.Outbox.Enabled)
+	importRepo := repository.NewImportRepository(db, repoLogger)
+	segmentRepo := repository.NewSegmentRepository(db)
+	statsRepo := repository.NewStatsRepository(db)
+	webhookRepo := repository.NewWebhookRepository(db)
+
+	sapClient, err := sap.NewClient(cfg, levels.Logger(logger, logutil.ComponentSAP))
+	if err != nil {
+		logger.Error("failed to initialize SAP client", "error", err.Error())
+		os.Exit(1)
+	}
+	watchReload(cfg, logger, levels, sapClient)
+
+	if cfg.Outbox.Enabled {
+		broker, err := outbox.NewBroker(cfg, logger)
+		if err != nil {
+			logger.Error("failed to initialize outbox broker", "error", err.Error())
+			os.Exit(1)
+		}
+		defer broker.Close()
+
+		relay := outbox.NewRelay(cfg, logger, repository.NewOutboxRepository(db, repoLogger), broker)
+		go relay.Run(context.Background())
+	}
+
+	notifier := webhook.NewNotifier(cfg, logger, webhookRepo)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Webhook.Timeout)
+		defer cancel()
+		_ = notifier.Close(ctx)
+	}()
+
+	// Без кэша обработчики читают репозиторий напрямую; Refresh у nil-кэша ничего не делает
+	var segmentationCache *cache.Segmentation
+	var segmentationReader handlers.SegmentationReader = segmentationRepo
+	if cfg.Cache.Enabled {
+		segmentationCache = cache.NewSegmentation(cfg, repoLogger, segmentationRepo)
+		segmentationReader = segmentationCache
+	}
 
-	sapClient := sap.NewClient(cfg, logger)
+	hub := progress.NewHub()
+	importService := importer.NewService(cfg, logger, sapClient, validation.NewValidator(cfg), segmentationRepo, importRepo, segmentRepo, statsRepo, segmentationCache, notifier, hub)
 
 	runImportOnStart := os.Getenv("RUN_IMPORT_ON_START") == "true"
 	if runImportOnStart {
 		go func() {
 			logger.Info("starting initial import from SAP API")
-			segments, err := sapClient.FetchSegmentation()
-			if err != nil {
-				logger.Error("failed to fetch segmentation data", "error", err.Error())
-				return
-			}
-
-			if len(segments) == 0 {
-				logger.Info("no segmentation data to import")
-				return
-			}
-
-			logger.Info("importing segmentation data to database", "count", len(segments))
-			if err := segmentationRepo.InsertOrUpdate(segments); err != nil {
-				logger.Error("failed to import segmentation data", "error", err.Error())
-				return
-			}
-
-			logger.Info("initial import completed successfully", "total_imported", len(segments))
+			// Результат и ошибки импорта логируются сервисом
+			_, _ = importService.Run(context.Background(), "")
 		}()
 	}
 
-	server := api.NewServer(cfg, logger, sapClient, segmentationRepo)
+	server := api.NewServer(cfg, logger, levels, db, sapClient, importService, segmentationReader, importRepo, segmentRepo, statsRepo, webhookRepo, hub)
 	if err := server.Run(":" + cfg.App.Port); err != nil {
 		logger.Error("failed to start server", "error", err.Error())
 		os.Exit(1)
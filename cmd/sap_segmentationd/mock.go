@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"go-test/internal/models"
+	"go-test/internal/sap/sapmock"
+)
+
+// runMockSAP запускает имитацию SAP API: sap_segmentationd mock-sap [флаги]
+func runMockSAP(args []string) {
+	fs := flag.NewFlagSet("mock-sap", flag.ExitOnError)
+	addr := fs.String("addr", ":8090", "адрес HTTP-сервера имитации")
+	fixture := fs.String("fixture", "", "JSON-файл с набором данных (по умолчанию встроенный)")
+	user := fs.String("user", "", "логин для Basic-аутентификации (пусто - без аутентификации)")
+	password := fs.String("password", "", "пароль для Basic-аутентификации")
+	token := fs.String("token", "", "статический bearer-токен")
+	clientID := fs.String("client-id", "", "client_id для выдачи токенов OAuth2 (пусто - OAuth2 выключен)")
+	clientSecret := fs.String("client-secret", "", "client_secret для выдачи токенов OAuth2")
+	tokenTTL := fs.Duration("token-ttl", time.Hour, "срок действия токенов OAuth2")
+	latency := fs.Duration("latency", 0, "задержка перед каждым ответом")
+	_ = fs.Parse(args)
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	var dataset []models.Segmentation
+	if *fixture != "" {
+		var err error
+		if dataset, err = sapmock.LoadDataset(*fixture); err != nil {
+			logger.Error("failed to load fixture", "error", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	server, err := sapmock.NewServer(sapmock.Options{
+		Username:     *user,
+		Password:     *password,
+		BearerToken:  *token,
+		ClientID:     *clientID,
+		ClientSecret: *clientSecret,
+		TokenTTL:     *tokenTTL,
+		Latency:      *latency,
+		Dataset:      dataset,
+		Logger:       logger,
+	})
+	if err != nil {
+		logger.Error("failed to create mock SAP server", "error", err.Error())
+		os.Exit(1)
+	}
+
+	logger.Info("starting mock SAP server",
+		"address", *addr,
+		"path", sapmock.SegmentationPath,
+		"fault_path", sapmock.FaultPath,
+		"token_path", sapmock.TokenPath,
+	)
+	if err := http.ListenAndServe(*addr, server); err != nil {
+		logger.Error("mock SAP server stopped", "error", err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+
+	"go-test/internal/logutil"
+	"go-test/internal/sap"
+	"go-test/pkg/config"
+)
+
+// watchReload перечитывает конфигурацию по SIGHUP и применяет настройки,
+// безопасные для изменения на ходу: уровни логирования, размер страницы
+// и частоту запросов к SAP API. Об изменении остальных настроек пишется
+// предупреждение - они вступят в силу после перезапуска.
+func watchReload(cfg *config.Config, logger *slog.Logger, levels *logutil.Levels, sapClient *sap.Client) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		current := cfg
+		for range signals {
+			next, err := config.Load()
+			if err != nil {
+				logger.Error("failed to reload config, keeping current settings", "error", err.Error())
+				continue
+			}
+
+			levels.Apply(next)
+			sapClient.Reload(next)
+
+			if !reflect.DeepEqual(withoutReloadable(current), withoutReloadable(next)) {
+				logger.Warn("config contains changes that require a restart")
+			}
+
+			logger.Info("config reloaded",
+				"log_level", next.LogLevel().String(),
+				"batch_size", next.Import.BatchSize,
+				"interval", next.Connection.Interval,
+				"rate_limit", next.Connection.RateLimit,
+			)
+			current = next
+		}
+	}()
+}
+
+// withoutReloadable возвращает копию конфигурации без настроек,
+// применяемых при перезагрузке
+func withoutReloadable(cfg *config.Config) config.Config {
+	c := *cfg
+	c.Log.Level, c.Log.SAP, c.Log.Repository, c.Log.HTTP = "", "", "", ""
+	c.Import.BatchSize = 0
+	c.Connection.Interval = 0
+	c.Connection.RateLimit = 0
+
+	return c
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"go-test/internal/webhook/webhookmock"
+)
+
+// runWebhookReceiver запускает получателя уведомлений для локальной
+// проверки: sap_segmentationd webhook-receiver [флаги]
+func runWebhookReceiver(args []string) {
+	fs := flag.NewFlagSet("webhook-receiver", flag.ExitOnError)
+	addr := fs.String("addr", ":8091", "адрес HTTP-сервера получателя")
+	secret := fs.String("secret", "", "ключ проверки подписи (пусто - без проверки)")
+	failFirst := fs.Int("fail-first", 0, "сколько первых запросов получат ошибку")
+	failStatus := fs.Int("fail-status", http.StatusInternalServerError, "HTTP-статус для -fail-first")
+	_ = fs.Parse(args)
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	receiver := webhookmock.NewReceiver(webhookmock.Options{
+		Secret:     *secret,
+		FailFirst:  *failFirst,
+		FailStatus: *failStatus,
+		Logger:     logger,
+	})
+
+	logger.Info("starting webhook receiver", "address", *addr)
+	if err := http.ListenAndServe(*addr, receiver); err != nil {
+		logger.Error("webhook receiver stopped", "error", err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-test/internal/logutil"
+)
+
+// maxRequestIDLength ограничивает длину идентификатора, принятого от клиента
+const maxRequestIDLength = 128
+
+// requestIDMiddleware принимает идентификатор запроса из X-Request-ID или
+// создает новый, возвращает его в ответе и кладет в контекст запроса вместе
+// с логгером запроса
+func requestIDMiddleware(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(logutil.RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		c.Header(logutil.RequestIDHeader, id)
+
+		ctx := logutil.WithRequestID(c.Request.Context(), id)
+		ctx = logutil.WithLogger(ctx, logger.With(logutil.RequestIDKey, id))
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
+
+// validRequestID допускает непустые идентификаторы из печатных ASCII-символов
+// без пробелов, чтобы они безопасно попадали в логи и заголовки
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// loggerMiddleware пишет начало и завершение запроса логгером запроса
+func loggerMiddleware(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		reqLogger := logutil.FromContext(c.Request.Context(), logger)
+
+		reqLogger.Info("request started",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"ip", c.ClientIP(),
+		)
+
+		c.Next()
+
+		reqLogger.Info("request completed",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"latency", time.Since(start),
+			"bytes", max(c.Writer.Size(), 0),
+			"ip", c.ClientIP(),
+			"user_agent", c.Request.UserAgent(),
+		)
+	}
+}
@@ -1,14 +1,21 @@
 package api
 
 import (
+	"crypto/subtle"
 	"log/slog"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 
 	"go-test/internal/handlers"
+	"go-test/internal/importer"
+	"go-test/internal/logutil"
+	"go-test/internal/metrics"
+	"go-test/internal/progress"
 	"go-test/internal/repository"
 	"go-test/internal/sap"
 	"go-test/pkg/config"
@@ -19,34 +26,59 @@ type Server struct {
 	logger              *slog.Logger
 	cfg                 *config.Config
 	segmentationHandler *handlers.SegmentationHandler
+	importHandler       *handlers.ImportHandler
 	healthHandler       *handlers.HealthHandler
+	adminHandler        *handlers.AdminHandler
+	webhookHandler      *handlers.WebhookHandler
+	segmentHandler      *handlers.SegmentHandler
 }
 
 func NewServer(
 	cfg *config.Config,
 	logger *slog.Logger,
+	levels *logutil.Levels,
+	db *sqlx.DB,
 	sapClient *sap.Client,
-	segmentationRepo *repository.SegmentationRepository,
+	importService *importer.Service,
+	segmentationRepo handlers.SegmentationReader,
+	importRepo *repository.ImportRepository,
+	segmentRepo *repository.SegmentRepository,
+	statsRepo *repository.StatsRepository,
+	webhookRepo *repository.WebhookRepository,
+	hub *progress.Hub,
 ) *Server {
 	if cfg.Env == "prod" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	// HTTP-слой логирует под своим компонентом
+	logger = levels.Logger(logger, logutil.ComponentHTTP)
+
 	router := gin.New()
 
-	router.Use(gin.Recovery())
+	// Recovery внутри, чтобы запрос с паникой тоже попал в лог
+	router.Use(requestIDMiddleware(logger))
 	router.Use(loggerMiddleware(logger))
+	router.Use(gin.Recovery())
 
 	// Инициализация обработчиков
-	segmentationHandler := handlers.NewSegmentationHandler(logger, sapClient, segmentationRepo)
-	healthHandler := handlers.NewHealthHandler(logger)
+	segmentationHandler := handlers.NewSegmentationHandler(logger, importService, segmentationRepo, statsRepo)
+	importHandler := handlers.NewImportHandler(logger, importRepo, hub)
+	healthHandler := handlers.NewHealthHandler(logger, db, sapClient)
+	adminHandler := handlers.NewAdminHandler(logger, levels)
+	webhookHandler := handlers.NewWebhookHandler(logger, webhookRepo)
+	segmentHandler := handlers.NewSegmentHandler(logger, segmentRepo)
 
 	server := &Server{
 		router:              router,
 		logger:              logger,
 		cfg:                 cfg,
 		segmentationHandler: segmentationHandler,
+		importHandler:       importHandler,
 		healthHandler:       healthHandler,
+		adminHandler:        adminHandler,
+		webhookHandler:      webhookHandler,
+		segmentHandler:      segmentHandler,
 	}
 
 	server.initRoutes()
@@ -54,21 +86,23 @@ func NewServer(
 	return server
 }
 
-func loggerMiddleware(logger *slog.Logger) gin.HandlerFunc {
+// adminAuth пропускает запросы с заголовком Authorization: Bearer <ADMIN_TOKEN>.
+// Если токен не задан, административный API выключен.
+func adminAuth(token config.Secret) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		logger.Info("request started",
-			"method", c.Request.Method,
-			"path", c.Request.URL.Path,
-			"ip", c.ClientIP(),
-		)
+		if !token.IsSet() {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin API is disabled"})
+			return
+		}
 
-		c.Next()
+		provided, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token.Value())) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
 
-		logger.Info("request completed",
-			"method", c.Request.Method,
-			"path", c.Request.URL.Path,
-			"status", c.Writer.Status(),
-		)
+		c.Next()
 	}
 }
 
@@ -78,13 +112,41 @@ func (s *Server) initRoutes() {
 		segmentation := api.Group("/segmentation")
 		{
 			segmentation.GET("/", s.segmentationHandler.GetAll)
+			segmentation.GET("/stats", s.segmentationHandler.Stats)
+			segmentation.GET("/export", s.segmentationHandler.Export)
 			segmentation.GET("/:id", s.segmentationHandler.GetByID)
 			segmentation.POST("/import", s.segmentationHandler.Import)
 		}
 
+		imports := api.Group("/imports")
+		{
+			imports.GET("/:id/rejects", s.importHandler.GetRejects)
+			imports.GET("/:id/events", s.importHandler.Events)
+		}
+
+		// Справочник читается свободно, изменяется с ADMIN_TOKEN
+		segments := api.Group("/segments")
+		{
+			segments.GET("/", s.segmentHandler.GetAll)
+			segments.GET("/:id", s.segmentHandler.GetByID)
+			segments.POST("/", adminAuth(s.cfg.App.AdminToken), s.segmentHandler.Create)
+			segments.PUT("/:id", adminAuth(s.cfg.App.AdminToken), s.segmentHandler.Update)
+			segments.DELETE("/:id", adminAuth(s.cfg.App.AdminToken), s.segmentHandler.Delete)
+		}
+
 		api.GET("/health", s.healthHandler.Check)
+		api.GET("/ready", s.healthHandler.Ready)
+
+		admin := api.Group("/admin", adminAuth(s.cfg.App.AdminToken))
+		{
+			admin.GET("/log-level", s.adminHandler.GetLogLevel)
+			admin.PUT("/log-level", s.adminHandler.SetLogLevel)
+			admin.GET("/webhooks/deliveries", s.webhookHandler.GetDeliveries)
+		}
 	}
 
+	s.router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
 	s.router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	s.router.GET("/", func(c *gin.Context) {
@@ -92,6 +154,11 @@ func (s *Server) initRoutes() {
 	})
 }
 
+// Handler возвращает HTTP-обработчик сервера, например для httptest
+func (s *Server) Handler() http.Handler {
+	return s.router
+}
+
 func (s *Server) Run(addr string) error {
 	s.logger.Info("starting API server", "address", addr)
 	return s.router.Run(addr)
@@ -0,0 +1,371 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-test/internal/metrics"
+	"go-test/internal/models"
+	"go-test/internal/repository"
+	"go-test/pkg/config"
+)
+
+var (
+	requestsTotal = metrics.NewCounterVec("segmentation_cache_requests_total",
+		"Обращения к кэшу сегментации", "method", "result")
+	entriesGauge = metrics.NewGauge("segmentation_cache_entries",
+		"Количество записей в кэше сегментации")
+)
+
+// Методы и результаты для метрики segmentation_cache_requests_total
+const (
+	methodGet  = "get"
+	methodList = "list"
+
+	resultHit  = "hit"
+	resultMiss = "miss"
+	// resultNotFound - отсутствие записи подтверждено кэшем без обращения к базе
+	resultNotFound = "not_found"
+)
+
+// versionCheckTimeout ограничивает сверку версии данных с базой
+const versionCheckTimeout = 5 * time.Second
+
+// errSnapshotDisabled - набор данных слишком велик для снимка
+var errSnapshotDisabled = errors.New("segmentation snapshot is disabled")
+
+// Segmentation - кэш чтения сегментации перед репозиторием. В режиме LRU
+// кэшируются отдельные записи (и их отсутствие) и полный список; в режиме
+// снимка весь набор данных хранится в памяти и заменяется целиком. Записи
+// живут не дольше CACHE_TTL; после успешного импорта кэш сбрасывается или
+// снимок перестраивается (Refresh). Импорт на другом экземпляре сервиса
+// обнаруживается сверкой версии данных в базе не чаще раза в
+// CACHE_VERSION_CHECK_INTERVAL. Возвращаемые записи общие для всех
+// читателей и не должны изменяться.
+type Segmentation struct {
+	logger          *slog.Logger
+	repo            *repository.SegmentationRepository
+	ttl             time.Duration
+	snapshotMaxRows int
+
+	versionCheckInterval time.Duration
+	checkMu              sync.Mutex
+	lastCheck            atomic.Int64
+	knownVersion         atomic.Pointer[models.SegmentationVersion]
+
+	// mu упорядочивает сохранение в LRU и сброс: значение, прочитанное из
+	// базы до сброса, не попадает в кэш после него
+	mu         sync.Mutex
+	generation uint64
+	items      *LRU[string, *models.Segmentation]
+	all        atomic.Pointer[listEntry]
+
+	useSnapshot atomic.Bool
+	snapshot    atomic.Pointer[snapshot]
+	rebuildMu   sync.Mutex
+}
+
+type listEntry struct {
+	segments  []*models.Segmentation
+	version   models.SegmentationVersion
+	expiresAt time.Time
+}
+
+type snapshot struct {
+	byID      map[string]*models.Segmentation
+	all       []*models.Segmentation
+	version   models.SegmentationVersion
+	expiresAt time.Time
+}
+
+func NewSegmentation(cfg *config.Config, logger *slog.Logger, repo *repository.SegmentationRepository) *Segmentation {
+	c := &Segmentation{
+		logger:          logger,
+		repo:            repo,
+		ttl:             cfg.Cache.TTL,
+		snapshotMaxRows: cfg.Cache.SnapshotMaxRows,
+
+		versionCheckInterval: cfg.Cache.VersionCheckInterval,
+
+		items: NewLRU[string, *models.Segmentation](cfg.Cache.Size, cfg.Cache.TTL),
+	}
+	c.useSnapshot.Store(cfg.Cache.Snapshot)
+
+	return c
+}
+
+// GetByAddressSapID возвращает запись или sql.ErrNoRows, если ее нет
+func (c *Segmentation) GetByAddressSapID(addressSapID string) (*models.Segmentation, error) {
+	c.checkVersion()
+
+	if snap, err := c.currentSnapshot(); err == nil {
+		if segment, ok := snap.byID[addressSapID]; ok {
+			requestsTotal.With(methodGet, resultHit).Inc()
+			return segment, nil
+		}
+		requestsTotal.With(methodGet, resultNotFound).Inc()
+		return nil, sql.ErrNoRows
+	} else if !errors.Is(err, errSnapshotDisabled) {
+		return nil, err
+	}
+
+	if segment, ok := c.items.Get(addressSapID); ok {
+		if segment == nil {
+			requestsTotal.With(methodGet, resultNotFound).Inc()
+			return nil, sql.ErrNoRows
+		}
+		requestsTotal.With(methodGet, resultHit).Inc()
+		return segment, nil
+	}
+	requestsTotal.With(methodGet, resultMiss).Inc()
+
+	generation := c.currentGeneration()
+
+	segment, err := c.repo.GetByAddressSapID(addressSapID)
+	notFound := errors.Is(err, sql.ErrNoRows)
+	if err != nil && !notFound {
+		return nil, err
+	}
+	if notFound {
+		segment = nil
+	}
+
+	c.mu.Lock()
+	if c.generation == generation {
+		c.items.Add(addressSapID, segment)
+		entriesGauge.Set(float64(c.items.Len()))
+	}
+	c.mu.Unlock()
+
+	if notFound {
+		return nil, sql.ErrNoRows
+	}
+
+	return segment, nil
+}
+
+// GetAll возвращает все записи
+func (c *Segmentation) GetAll() ([]*models.Segmentation, error) {
+	c.checkVersion()
+
+	if snap, err := c.currentSnapshot(); err == nil {
+		requestsTotal.With(methodList, resultHit).Inc()
+		return snap.all, nil
+	} else if !errors.Is(err, errSnapshotDisabled) {
+		return nil, err
+	}
+
+	if entry := c.all.Load(); entry != nil && time.Now().Before(entry.expiresAt) {
+		requestsTotal.With(methodList, resultHit).Inc()
+		return entry.segments, nil
+	}
+	requestsTotal.With(methodList, resultMiss).Inc()
+
+	generation := c.currentGeneration()
+
+	segments, err := c.repo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.generation == generation {
+		c.all.Store(&listEntry{
+			segments:  segments,
+			version:   versionOf(segments),
+			expiresAt: time.Now().Add(c.ttl),
+		})
+	}
+	c.mu.Unlock()
+
+	return segments, nil
+}
+
+// Version возвращает версию набора данных, который сейчас отдает GetAll:
+// снимка или закэшированного списка. Если список не закэширован, версия
+// читается из базы; GetAll после нее вернет данные не старее этой версии.
+func (c *Segmentation) Version(ctx context.Context) (models.SegmentationVersion, error) {
+	c.checkVersion()
+
+	if snap, err := c.currentSnapshot(); err == nil {
+		return snap.version, nil
+	} else if !errors.Is(err, errSnapshotDisabled) {
+		return models.SegmentationVersion{}, err
+	}
+
+	if entry := c.all.Load(); entry != nil && time.Now().Before(entry.expiresAt) {
+		return entry.version, nil
+	}
+
+	return c.repo.Version(ctx)
+}
+
+// Refresh вызывается после успешного импорта: LRU-кэш сбрасывается, а
+// снимок перестраивается и заменяется целиком, так что до замены читатели
+// получают прежний снимок. Если перестроить снимок не удалось, он
+// сбрасывается и будет построен при следующем чтении.
+func (c *Segmentation) Refresh(ctx context.Context) {
+	if c == nil {
+		return
+	}
+
+	c.invalidate()
+
+	if !c.useSnapshot.Load() {
+		return
+	}
+
+	c.rebuildMu.Lock()
+	defer c.rebuildMu.Unlock()
+
+	if _, err := c.rebuild(); err != nil && !errors.Is(err, errSnapshotDisabled) {
+		c.snapshot.Store(nil)
+		c.logger.ErrorContext(ctx, "failed to rebuild segmentation snapshot", "error", err.Error())
+	}
+}
+
+// checkVersion не чаще раза в CACHE_VERSION_CHECK_INTERVAL в фоне сверяет
+// версию данных в базе с замеченной при прошлой сверке и при расхождении
+// сбрасывает кэш, как после импорта
+func (c *Segmentation) checkVersion() {
+	if c.versionCheckInterval <= 0 {
+		return
+	}
+
+	now := time.Now().UnixNano()
+	if now-c.lastCheck.Load() < int64(c.versionCheckInterval) || !c.checkMu.TryLock() {
+		return
+	}
+	c.lastCheck.Store(now)
+
+	go func() {
+		defer c.checkMu.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), versionCheckTimeout)
+		defer cancel()
+
+		version, err := c.repo.Version(ctx)
+		if err != nil {
+			c.logger.Warn("failed to check segmentation version", "error", err.Error())
+			return
+		}
+
+		// Снимок и список сверяются и по собственной версии: они могли быть
+		// загружены до первой сверки
+		prev := c.knownVersion.Swap(&version)
+		stale := prev != nil && !prev.Equal(version)
+		if snap := c.snapshot.Load(); snap != nil && !snap.version.Equal(version) {
+			stale = true
+		}
+		if entry := c.all.Load(); entry != nil && !entry.version.Equal(version) {
+			stale = true
+		}
+
+		if stale {
+			c.logger.Debug("segmentation changed in database, refreshing cache",
+				"rows", version.Rows,
+				"last_modified", version.LastModified,
+			)
+			c.Refresh(ctx)
+		}
+	}()
+}
+
+func (c *Segmentation) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.items.Purge()
+	c.all.Store(nil)
+	entriesGauge.Set(0)
+}
+
+func (c *Segmentation) currentGeneration() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+// currentSnapshot возвращает снимок, при необходимости строя его. Устаревший
+// снимок отдается, пока в фоне строится новый.
+func (c *Segmentation) currentSnapshot() (*snapshot, error) {
+	if !c.useSnapshot.Load() {
+		return nil, errSnapshotDisabled
+	}
+
+	if snap := c.snapshot.Load(); snap != nil {
+		if time.Now().After(snap.expiresAt) && c.rebuildMu.TryLock() {
+			go func() {
+				defer c.rebuildMu.Unlock()
+				if _, err := c.rebuild(); err != nil && !errors.Is(err, errSnapshotDisabled) {
+					c.logger.Error("failed to rebuild segmentation snapshot", "error", err.Error())
+				}
+			}()
+		}
+		return snap, nil
+	}
+
+	c.rebuildMu.Lock()
+	defer c.rebuildMu.Unlock()
+
+	// Снимок мог построить другой читатель, пока мы ждали
+	if snap := c.snapshot.Load(); snap != nil {
+		return snap, nil
+	}
+
+	return c.rebuild()
+}
+
+// rebuild строит снимок; вызывается под rebuildMu. Если записей больше
+// CACHE_SNAPSHOT_MAX_ROWS, режим снимка выключается до перезапуска.
+func (c *Segmentation) rebuild() (*snapshot, error) {
+	segments, err := c.repo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(segments) > c.snapshotMaxRows {
+		c.useSnapshot.Store(false)
+		c.snapshot.Store(nil)
+		c.logger.Warn("segmentation dataset is too large for snapshot, using LRU cache",
+			"rows", len(segments),
+			"max_rows", c.snapshotMaxRows,
+		)
+		return nil, errSnapshotDisabled
+	}
+
+	snap := &snapshot{
+		byID:      make(map[string]*models.Segmentation, len(segments)),
+		all:       segments,
+		version:   versionOf(segments),
+		expiresAt: time.Now().Add(c.ttl),
+	}
+	for _, segment := range segments {
+		snap.byID[segment.AddressSapID] = segment
+	}
+
+	c.snapshot.Store(snap)
+	entriesGauge.Set(float64(len(segments)))
+	c.logger.Debug("segmentation snapshot rebuilt", "rows", len(segments))
+
+	return snap, nil
+}
+
+// versionOf считает версию набора так же, как SegmentationRepository.Version
+func versionOf(segments []*models.Segmentation) models.SegmentationVersion {
+	version := models.SegmentationVersion{Rows: int64(len(segments))}
+	for _, segment := range segments {
+		if segment.UpdatedAt.After(version.LastModified) {
+			version.LastModified = segment.UpdatedAt
+		}
+	}
+
+	return version
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+
+	"go-test/internal/logutil"
+)
+
+// AdminHandler обрабатывает административные запросы
+type AdminHandler struct {
+	logger *slog.Logger
+	levels *logutil.Levels
+}
+
+// NewAdminHandler создает новый обработчик административных запросов
+func NewAdminHandler(logger *slog.Logger, levels *logutil.Levels) *AdminHandler {
+	return &AdminHandler{
+		logger: logger,
+		levels: levels,
+	}
+}
+
+// LogLevelResponse - текущие уровни логирования
+type LogLevelResponse struct {
+	// Level - общий уровень
+	Level string `json:"level" example:"INFO"`
+	// Components - действующие уровни компонентов
+	Components map[string]string `json:"components"`
+	// Overrides - компоненты с собственным уровнем
+	Overrides map[string]string `json:"overrides"`
+}
+
+// LogLevelRequest - изменение уровней логирования. Пустой уровень компонента
+// сбрасывает его на общий.
+type LogLevelRequest struct {
+	Level      *string           `json:"level,omitempty" example:"debug"`
+	Components map[string]string `json:"components,omitempty"`
+}
+
+// GetLogLevel возвращает текущие уровни логирования
+// @Summary Получить уровни логирования
+// @Description Возвращает общий уровень логирования и уровни компонентов (sap, repository, http)
+// @Tags admin
+// @Produce json
+// @Security AdminToken
+// @Success 200 {object} LogLevelResponse
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /api/admin/log-level [get]
+func (h *AdminHandler) GetLogLevel(c *gin.Context) {
+	c.JSON(http.StatusOK, h.logLevels())
+}
+
+// SetLogLevel меняет уровни логирования без перезапуска
+// @Summary Изменить уровни логирования
+// @Description Меняет общий уровень и/или уровни компонентов. Пустой уровень компонента сбрасывает его на общий. Изменения действуют до перезапуска или SIGHUP.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param request body LogLevelRequest true "Новые уровни"
+// @Success 200 {object} LogLevelResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /api/admin/log-level [put]
+func (h *AdminHandler) SetLogLevel(c *gin.Context) {
+	var req LogLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	// Сначала проверяем все значения, чтобы не применить запрос частично
+	var global slog.Level
+	if req.Level != nil {
+		if err := global.UnmarshalText([]byte(*req.Level)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid level: " + *req.Level})
+			return
+		}
+	}
+
+	components := make(map[string]*slog.Level, len(req.Components))
+	for component, value := range req.Components {
+		if !slices.Contains(logutil.Components, component) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown component: " + component})
+			return
+		}
+
+		if value == "" {
+			components[component] = nil
+			continue
+		}
+
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(value)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid level for " + component + ": " + value})
+			return
+		}
+		components[component] = &level
+	}
+
+	if req.Level != nil {
+		h.levels.SetGlobal(global)
+	}
+	for component, level := range components {
+		_ = h.levels.SetComponent(component, level)
+	}
+
+	resp := h.logLevels()
+	requestLogger(c, h.logger).Warn("log levels changed via admin API",
+		"level", resp.Level,
+		"overrides", resp.Overrides,
+		"ip", c.ClientIP(),
+	)
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *AdminHandler) logLevels() LogLevelResponse {
+	resp := LogLevelResponse{
+		Level:      h.levels.Global().String(),
+		Components: make(map[string]string, len(logutil.Components)),
+		Overrides:  make(map[string]string),
+	}
+
+	for _, component := range logutil.Components {
+		resp.Components[component] = h.levels.Level(component).String()
+	}
+
+	for component, level := range h.levels.Overrides() {
+		resp.Overrides[component] = level.String()
+	}
+
+	return resp
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go-test/internal/models"
+)
+
+// rowVersion - хэш полей записи, попадающих в ответ; меняется вместе с updated_at
+func rowVersion(segment *models.Segmentation) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(segment.AddressSapID))
+	h.Write([]byte{0})
+	h.Write([]byte(segment.AdrSegment))
+	h.Write([]byte{0})
+	h.Write(strconv.AppendInt(nil, segment.SegmentID, 10))
+	h.Write([]byte{0})
+	h.Write(strconv.AppendInt(nil, segment.CreatedAt.UnixNano(), 10))
+	h.Write([]byte{0})
+	h.Write(strconv.AppendInt(nil, segment.UpdatedAt.UnixNano(), 10))
+
+	return h.Sum64()
+}
+
+// segmentETag возвращает ETag записи
+func segmentETag(segment *models.Segmentation) string {
+	return fmt.Sprintf(`"%016x"`, rowVersion(segment))
+}
+
+// datasetETag возвращает ETag набора записей по его версии, чтобы
+// проверить условный запрос до чтения записей. kind различает представления
+// набора (список, выгрузка), у которых должны быть разные ETag.
+func datasetETag(kind string, version models.SegmentationVersion) string {
+	return fmt.Sprintf(`"%s-%x-%x"`, kind, version.Rows, version.LastModified.UnixMicro())
+}
+
+// notModified выставляет ETag, Last-Modified и Cache-Control и, если у
+// клиента актуальное представление, отвечает 304. If-None-Match важнее
+// If-Modified-Since. Нулевой lastModified не выводится.
+func notModified(c *gin.Context, etag string, lastModified time.Time) bool {
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "no-cache")
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if inm := c.GetHeader("If-None-Match"); inm != "" {
+		if !etagMatches(inm, etag) {
+			return false
+		}
+		c.AbortWithStatus(http.StatusNotModified)
+		return true
+	}
+
+	if ims := c.GetHeader("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(ims)
+		// Last-Modified передается с точностью до секунды
+		if err != nil || lastModified.Truncate(time.Second).After(since) {
+			return false
+		}
+		c.AbortWithStatus(http.StatusNotModified)
+		return true
+	}
+
+	return false
+}
+
+// etagMatches сравнивает If-None-Match с ETag по слабому правилу (RFC 9110)
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,21 +1,33 @@
 package handlers
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	"go-test/internal/sap"
 )
 
+// readinessTimeout ограничивает время проверки базы данных
+const readinessTimeout = 2 * time.Second
+
 // HealthHandler обрабатывает запросы для проверки работоспособности системы
 type HealthHandler struct {
-	logger *slog.Logger
+	logger    *slog.Logger
+	db        *sqlx.DB
+	sapClient *sap.Client
 }
 
 // NewHealthHandler создает новый обработчик для проверки здоровья
-func NewHealthHandler(logger *slog.Logger) *HealthHandler {
+func NewHealthHandler(logger *slog.Logger, db *sqlx.DB, sapClient *sap.Client) *HealthHandler {
 	return &HealthHandler{
-		logger: logger,
+		logger:    logger,
+		db:        db,
+		sapClient: sapClient,
 	}
 }
 
@@ -28,6 +40,45 @@ func NewHealthHandler(logger *slog.Logger) *HealthHandler {
 // @Success 200 {object} map[string]string
 // @Router /api/health [get]
 func (h *HealthHandler) Check(c *gin.Context) {
-	h.logger.Debug("health check requested")
+	requestLogger(c, h.logger).Debug("health check requested")
 	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
+
+// Ready эндпоинт для проверки готовности сервера
+// @Summary Проверка готовности
+// @Description Проверяет доступность базы данных и состояние автоматического выключателя SAP API.
+// @Description При разомкнутом выключателе сервис продолжает отдавать данные и имеет статус degraded.
+// @Tags system
+// @Accept json
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 503 {object} map[string]interface{}
+// @Router /api/ready [get]
+func (h *HealthHandler) Ready(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	status := "ready"
+	code := http.StatusOK
+
+	database := "ok"
+	if err := h.db.PingContext(ctx); err != nil {
+		requestLogger(c, h.logger).Error("readiness check: database is unavailable", "error", err.Error())
+		database = "unavailable"
+		status = "not_ready"
+		code = http.StatusServiceUnavailable
+	}
+
+	breaker := h.sapClient.BreakerState()
+	if breaker != sap.BreakerClosed && code == http.StatusOK {
+		status = "degraded"
+	}
+
+	c.JSON(code, gin.H{
+		"status": status,
+		"checks": gin.H{
+			"database":          database,
+			"sap_circuit_state": breaker.String(),
+		},
+	})
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+
+	"go-test/internal/models"
+	"go-test/internal/progress"
+	"go-test/internal/repository"
+)
+
+// keepaliveInterval - периодичность комментариев в потоке событий, чтобы
+// прокси не закрывали соединение во время долгих шагов импорта
+const keepaliveInterval = 15 * time.Second
+
+// ImportHandler обрабатывает запросы к истории импортов
+type ImportHandler struct {
+	logger     *slog.Logger
+	importRepo *repository.ImportRepository
+	hub        *progress.Hub
+}
+
+// NewImportHandler создает новый обработчик для истории импортов
+func NewImportHandler(logger *slog.Logger, importRepo *repository.ImportRepository, hub *progress.Hub) *ImportHandler {
+	return &ImportHandler{
+		logger:     logger,
+		importRepo: importRepo,
+		hub:        hub,
+	}
+}
+
+// GetRejects возвращает записи, отклоненные при импорте
+// @Summary Получить отклоненные записи импорта
+// @Description Возвращает записи из SAP, не прошедшие валидацию и помещенные в карантин
+// @Tags imports
+// @Accept json
+// @Produce json
+// @Param id path int true "ID импорта"
+// @Success 200 {array} models.Reject
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/imports/{id}/rejects [get]
+func (h *ImportHandler) GetRejects(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid import id"})
+		return
+	}
+
+	if _, err := h.importRepo.GetByID(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "import not found"})
+			return
+		}
+		requestLogger(c, h.logger).Error("failed to get import", "error", err.Error(), "id", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get import"})
+		return
+	}
+
+	rejects, err := h.importRepo.GetRejects(id)
+	if err != nil {
+		requestLogger(c, h.logger).Error("failed to get import rejects", "error", err.Error(), "id", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get import rejects"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rejects)
+}
+
+// Events транслирует ход импорта как server-sent events
+// @Summary Поток событий хода импорта
+// @Description Server-sent events: started, page_fetched, retry, validated, batch_upserted и completed с накопленными показателями.
+// @Description Идентификатор события - его номер; при переподключении с заголовком Last-Event-ID поток продолжается со следующего события.
+// @Description Для завершенного импорта, события которого уже не хранятся, отправляется одно событие completed по записи из истории.
+// @Tags imports
+// @Produce text/event-stream
+// @Param id path int true "ID импорта"
+// @Param Last-Event-ID header int false "Номер последнего полученного события"
+// @Success 200 {object} progress.Event
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/imports/{id}/events [get]
+func (h *ImportHandler) Events(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid import id"})
+		return
+	}
+
+	var from int
+	if lastID := c.GetHeader("Last-Event-ID"); lastID != "" {
+		if from, err = strconv.Atoi(lastID); err != nil || from < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid Last-Event-ID"})
+			return
+		}
+	}
+
+	tracker, ok := h.hub.Get(id)
+	if !ok {
+		h.completedEvent(c, id)
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	keepalive := time.NewTicker(keepaliveInterval)
+	defer keepalive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		events, done, changed := tracker.Since(from)
+		for _, event := range events {
+			from++
+			c.Render(-1, sse.Event{
+				Id:    strconv.Itoa(from),
+				Event: event.Type,
+				Data:  event,
+			})
+		}
+		if done {
+			return false
+		}
+		if len(events) > 0 {
+			return true
+		}
+
+		select {
+		case <-changed:
+		case <-keepalive.C:
+			_, _ = io.WriteString(w, ": keepalive\n\n")
+		case <-c.Request.Context().Done():
+			return false
+		}
+
+		return true
+	})
+}
+
+// completedEvent отправляет итог импорта, трекера которого нет в памяти
+func (h *ImportHandler) completedEvent(c *gin.Context, id int64) {
+	imp, err := h.importRepo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "import not found"})
+			return
+		}
+		requestLogger(c, h.logger).Error("failed to get import", "error", err.Error(), "id", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get import"})
+		return
+	}
+
+	// Импорт выполняется другим экземпляром сервиса или был прерван
+	// перезапуском: событий его хода здесь нет
+	if imp.Status == models.ImportStatusRunning {
+		c.JSON(http.StatusNotFound, gin.H{"error": "import progress is not available"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Render(http.StatusOK, sse.Event{
+		Event: progress.EventCompleted,
+		Data:  progress.CompletedEvent(imp),
+	})
+}
@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+
+	"go-test/internal/logutil"
+)
+
+// requestLogger возвращает логгер запроса с его идентификатором или fallback
+func requestLogger(c *gin.Context, fallback *slog.Logger) *slog.Logger {
+	return logutil.FromContext(c.Request.Context(), fallback)
+}
@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"go-test/internal/models"
+	"go-test/internal/repository"
+)
+
+// Ограничения колонок таблицы segments
+const (
+	maxSegmentCodeLength = 16
+	maxSegmentNameLength = 255
+)
+
+// SegmentHandler обрабатывает запросы к справочнику сегментов
+type SegmentHandler struct {
+	logger      *slog.Logger
+	segmentRepo *repository.SegmentRepository
+}
+
+// NewSegmentHandler создает новый обработчик справочника сегментов
+func NewSegmentHandler(logger *slog.Logger, segmentRepo *repository.SegmentRepository) *SegmentHandler {
+	return &SegmentHandler{
+		logger:      logger,
+		segmentRepo: segmentRepo,
+	}
+}
+
+// CreateSegmentRequest - новый сегмент справочника
+type CreateSegmentRequest struct {
+	ID          int64  `json:"id" example:"1042"`
+	Code        string `json:"code" example:"VIP"`
+	Name        string `json:"name" example:"VIP-клиенты"`
+	Description string `json:"description"`
+	// Active - по умолчанию true
+	Active *bool `json:"active,omitempty"`
+}
+
+// UpdateSegmentRequest - изменяемые поля сегмента. id и код не меняются:
+// по ним записи SAP связываются со справочником.
+type UpdateSegmentRequest struct {
+	Name        string `json:"name" example:"VIP-клиенты"`
+	Description string `json:"description"`
+	Active      bool   `json:"active"`
+}
+
+// GetAll возвращает справочник сегментов
+// @Summary Получить справочник сегментов
+// @Description Возвращает сегменты по возрастанию id
+// @Tags segments
+// @Produce json
+// @Param active query bool false "Только активные сегменты"
+// @Success 200 {array} models.Segment
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/segments [get]
+func (h *SegmentHandler) GetAll(c *gin.Context) {
+	activeOnly, err := strconv.ParseBool(c.DefaultQuery("active", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid active parameter"})
+		return
+	}
+
+	segments, err := h.segmentRepo.GetAll(c.Request.Context(), activeOnly)
+	if err != nil {
+		requestLogger(c, h.logger).Error("failed to get segments", "error", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get segments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, segments)
+}
+
+// GetByID возвращает сегмент справочника
+// @Summary Получить сегмент справочника
+// @Tags segments
+// @Produce json
+// @Param id path int true "ID сегмента (segment_id)"
+// @Success 200 {object} models.Segment
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/segments/{id} [get]
+func (h *SegmentHandler) GetByID(c *gin.Context) {
+	id, ok := segmentID(c)
+	if !ok {
+		return
+	}
+
+	segment, err := h.segmentRepo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, err, id, "failed to get segment")
+		return
+	}
+
+	c.JSON(http.StatusOK, segment)
+}
+
+// Create добавляет сегмент в справочник
+// @Summary Добавить сегмент
+// @Description Добавляет сегмент в справочник. id соответствует segment_id, code - adr_segment из SAP.
+// @Tags segments
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param request body CreateSegmentRequest true "Сегмент"
+// @Success 201 {object} models.Segment
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/segments [post]
+func (h *SegmentHandler) Create(c *gin.Context) {
+	var req CreateSegmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	req.Code = strings.TrimSpace(req.Code)
+	switch {
+	case req.ID <= 0:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id must be positive"})
+		return
+	case req.Code == "" || utf8.RuneCountInString(req.Code) > maxSegmentCodeLength:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code must be 1 to 16 characters"})
+		return
+	case utf8.RuneCountInString(req.Name) > maxSegmentNameLength:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must be at most 255 characters"})
+		return
+	}
+
+	segment := &models.Segment{
+		ID:          req.ID,
+		Code:        req.Code,
+		Name:        req.Name,
+		Description: req.Description,
+		Active:      req.Active == nil || *req.Active,
+	}
+	if segment.Name == "" {
+		segment.Name = segment.Code
+	}
+
+	if err := h.segmentRepo.Create(c.Request.Context(), segment); err != nil {
+		h.respondError(c, err, req.ID, "failed to create segment")
+		return
+	}
+
+	requestLogger(c, h.logger).Info("segment created", "id", segment.ID, "code", segment.Code)
+
+	c.JSON(http.StatusCreated, segment)
+}
+
+// Update изменяет сегмент справочника
+// @Summary Изменить сегмент
+// @Description Меняет название, описание и признак активности. Записи неактивного сегмента отклоняются при импорте.
+// @Tags segments
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param id path int true "ID сегмента (segment_id)"
+// @Param request body UpdateSegmentRequest true "Изменяемые поля"
+// @Success 200 {object} models.Segment
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/segments/{id} [put]
+func (h *SegmentHandler) Update(c *gin.Context) {
+	id, ok := segmentID(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateSegmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	if req.Name == "" || utf8.RuneCountInString(req.Name) > maxSegmentNameLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must be 1 to 255 characters"})
+		return
+	}
+
+	segment := &models.Segment{
+		ID:          id,
+		Name:        req.Name,
+		Description: req.Description,
+		Active:      req.Active,
+	}
+	if err := h.segmentRepo.Update(c.Request.Context(), segment); err != nil {
+		h.respondError(c, err, id, "failed to update segment")
+		return
+	}
+
+	requestLogger(c, h.logger).Info("segment updated", "id", segment.ID, "active", segment.Active)
+
+	c.JSON(http.StatusOK, segment)
+}
+
+// Delete удаляет сегмент из справочника
+// @Summary Удалить сегмент
+// @Description Удаляет сегмент, на который не ссылаются записи сегментации. Используемый сегмент можно сделать неактивным.
+// @Tags segments
+// @Produce json
+// @Security AdminToken
+// @Param id path int true "ID сегмента (segment_id)"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/segments/{id} [delete]
+func (h *SegmentHandler) Delete(c *gin.Context) {
+	id, ok := segmentID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.segmentRepo.Delete(c.Request.Context(), id); err != nil {
+		h.respondError(c, err, id, "failed to delete segment")
+		return
+	}
+
+	requestLogger(c, h.logger).Info("segment deleted", "id", id)
+
+	c.Status(http.StatusNoContent)
+}
+
+func segmentID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid segment id"})
+		return 0, false
+	}
+
+	return id, true
+}
+
+// respondError отвечает на ошибку репозитория справочника
+func (h *SegmentHandler) respondError(c *gin.Context, err error, id int64, message string) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "segment not found"})
+	case errors.Is(err, repository.ErrSegmentExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "segment with this id or code already exists"})
+	case errors.Is(err, repository.ErrSegmentInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": "segment is referenced by segmentation records"})
+	default:
+		requestLogger(c, h.logger).Error(message, "error", err.Error(), "id", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -1,48 +1,78 @@
 package handlers
 
 import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"go-test/internal/importer"
+	"go-test/internal/models"
 	"go-test/internal/repository"
-	"go-test/internal/sap"
 )
 
+const (
+	defaultStatsImports = 5
+	maxStatsImports     = 100
+)
+
+// SegmentationReader читает сегментацию: репозиторий или кэш перед ним
+type SegmentationReader interface {
+	GetByAddressSapID(addressSapID string) (*models.Segmentation, error)
+	GetAll() ([]*models.Segmentation, error)
+	Version(ctx context.Context) (models.SegmentationVersion, error)
+}
+
 // SegmentationHandler обрабатывает запросы, связанные с сегментацией
 type SegmentationHandler struct {
 	logger           *slog.Logger
-	sapClient        *sap.Client
-	segmentationRepo *repository.SegmentationRepository
+	importer         *importer.Service
+	segmentationRepo SegmentationReader
+	statsRepo        *repository.StatsRepository
 }
 
 // NewSegmentationHandler создает новый обработчик для сегментации
 func NewSegmentationHandler(
 	logger *slog.Logger,
-	sapClient *sap.Client,
-	segmentationRepo *repository.SegmentationRepository,
+	importer *importer.Service,
+	segmentationRepo SegmentationReader,
+	statsRepo *repository.StatsRepository,
 ) *SegmentationHandler {
 	return &SegmentationHandler{
 		logger:           logger,
-		sapClient:        sapClient,
+		importer:         importer,
 		segmentationRepo: segmentationRepo,
+		statsRepo:        statsRepo,
 	}
 }
 
 // GetAll возвращает все сегменты
 // @Summary Получить все сегменты
-// @Description Возвращает список всех сегментов из базы данных
+// @Description Возвращает список всех сегментов из базы данных.
+// @Description Ответ содержит ETag и Last-Modified; при совпадении If-None-Match или If-Modified-Since возвращается 304.
 // @Tags segmentation
 // @Accept json
 // @Produce json
+// @Param If-None-Match header string false "ETag полученного ранее списка"
+// @Param If-Modified-Since header string false "Last-Modified полученного ранее списка"
 // @Success 200 {array} model.Segmentation
+// @Success 304
 // @Failure 500 {object} map[string]string
 // @Router /api/segmentation [get]
 func (h *SegmentationHandler) GetAll(c *gin.Context) {
+	if h.datasetNotModified(c, "list") {
+		return
+	}
+
 	segments, err := h.segmentationRepo.GetAll()
 	if err != nil {
-		h.logger.Error("failed to get all segments", "error", err.Error())
+		requestLogger(c, h.logger).Error("failed to get all segments", "error", err.Error())
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get segments"})
 		return
 	}
@@ -50,14 +80,78 @@ func (h *SegmentationHandler) GetAll(c *gin.Context) {
 	c.JSON(http.StatusOK, segments)
 }
 
+// Export выгружает все сегменты в CSV
+// @Summary Выгрузить сегменты
+// @Description Выгружает все сегменты в CSV: address_sap_id, adr_segment, segment_id, created_at, updated_at.
+// @Description Ответ содержит ETag и Last-Modified; при совпадении If-None-Match или If-Modified-Since возвращается 304.
+// @Tags segmentation
+// @Produce text/csv
+// @Param If-None-Match header string false "ETag полученной ранее выгрузки"
+// @Param If-Modified-Since header string false "Last-Modified полученной ранее выгрузки"
+// @Success 200 {string} string
+// @Success 304
+// @Failure 500 {object} map[string]string
+// @Router /api/segmentation/export [get]
+func (h *SegmentationHandler) Export(c *gin.Context) {
+	if h.datasetNotModified(c, "csv") {
+		return
+	}
+
+	segments, err := h.segmentationRepo.GetAll()
+	if err != nil {
+		requestLogger(c, h.logger).Error("failed to get all segments", "error", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get segments"})
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="segmentation.csv"`)
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write([]string{"address_sap_id", "adr_segment", "segment_id", "created_at", "updated_at"})
+	for _, segment := range segments {
+		_ = w.Write([]string{
+			segment.AddressSapID,
+			segment.AdrSegment,
+			strconv.FormatInt(segment.SegmentID, 10),
+			segment.CreatedAt.UTC().Format(time.RFC3339),
+			segment.UpdatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		requestLogger(c, h.logger).Warn("failed to write segmentation export", "error", err.Error())
+	}
+}
+
+// datasetNotModified проверяет условный запрос к набору записей по его
+// версии, не читая записи, и выставляет ETag и Last-Modified. Записи
+// читаются после версии, поэтому ответ не старее ETag. Если версию получить
+// не удалось, запрос обрабатывается без условных заголовков.
+func (h *SegmentationHandler) datasetNotModified(c *gin.Context, kind string) bool {
+	version, err := h.segmentationRepo.Version(c.Request.Context())
+	if err != nil {
+		requestLogger(c, h.logger).Warn("failed to get segmentation version", "error", err.Error())
+		return false
+	}
+
+	return notModified(c, datasetETag(kind, version), version.LastModified)
+}
+
 // GetByID возвращает сегмент по ID
 // @Summary Получить сегмент по ID
-// @Description Возвращает сегмент с указанным SAP ID
+// @Description Возвращает сегмент с указанным SAP ID.
+// @Description ETag и Last-Modified соответствуют версии записи; при совпадении If-None-Match или If-Modified-Since возвращается 304.
 // @Tags segmentation
 // @Accept json
 // @Produce json
 // @Param id path string true "SAP ID сегмента"
+// @Param If-None-Match header string false "ETag полученной ранее записи"
+// @Param If-Modified-Since header string false "Last-Modified полученной ранее записи"
 // @Success 200 {object} model.Segmentation
+// @Success 304
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /api/segmentation/{id} [get]
@@ -66,46 +160,138 @@ func (h *SegmentationHandler) GetByID(c *gin.Context) {
 
 	segment, err := h.segmentationRepo.GetByAddressSapID(id)
 	if err != nil {
-		h.logger.Error("failed to get segment by ID", "error", err.Error(), "id", id)
+		requestLogger(c, h.logger).Error("failed to get segment by ID", "error", err.Error(), "id", id)
 		c.JSON(http.StatusNotFound, gin.H{"error": "segment not found"})
 		return
 	}
 
+	if notModified(c, segmentETag(segment), segment.UpdatedAt) {
+		return
+	}
+
 	c.JSON(http.StatusOK, segment)
 }
 
+// Stats возвращает распределение адресов по сегментам
+// @Summary Статистика по сегментам
+// @Description Количество адресов по парам adr_segment/segment_id, итог и распределения после последних успешных импортов с изменением относительно предыдущего импорта.
+// @Description С group_by=prefix счетчики разбиваются по префиксу address_sap_id (SAP-, SEG-, ADR-).
+// @Tags segmentation
+// @Produce json
+// @Param imports query int false "Количество последних импортов (по умолчанию 5, от 0 до 100)"
+// @Param group_by query string false "Группировка: prefix"
+// @Success 200 {object} models.SegmentStats
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/segmentation/stats [get]
+func (h *SegmentationHandler) Stats(c *gin.Context) {
+	imports := defaultStatsImports
+	if raw := c.Query("imports"); raw != "" {
+		var err error
+		if imports, err = strconv.Atoi(raw); err != nil || imports < 0 || imports > maxStatsImports {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid imports parameter"})
+			return
+		}
+	}
+
+	groupBy := c.Query("group_by")
+	if groupBy != "" && groupBy != "prefix" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid group_by parameter"})
+		return
+	}
+
+	stats, err := h.statsRepo.Stats(c.Request.Context(), imports, groupBy == "prefix")
+	if err != nil {
+		requestLogger(c, h.logger).Error("failed to get segmentation stats", "error", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get segmentation stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
 // Import запускает импорт сегментации из SAP API
 // @Summary Импортировать сегментацию
-// @Description Запускает процесс импорта данных из SAP API в базу данных
+// @Description Запускает процесс импорта данных из SAP API в базу данных.
+// @Description С async=true импорт выполняется в фоне: ответ 202 содержит import_id и адрес потока событий хода импорта.
+// @Description Если импорт уже выполняется, возвращается 409 с import_id выполняющегося импорта.
 // @Tags segmentation
 // @Accept json
 // @Produce json
+// @Param mode query string false "Режим импорта: full или incremental (по умолчанию из конфигурации)"
+// @Param async query bool false "Запустить импорт в фоне"
 // @Success 200 {object} map[string]interface{}
+// @Success 202 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
 // @Failure 500 {object} map[string]string
 // @Router /api/segmentation/import [post]
 func (h *SegmentationHandler) Import(c *gin.Context) {
-	h.logger.Info("starting segmentation import")
+	mode := models.ImportMode(c.Query("mode"))
+	if mode != "" && mode != models.ImportModeFull && mode != models.ImportModeIncremental {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid import mode"})
+		return
+	}
 
-	segments, err := h.sapClient.FetchSegmentation()
+	async, err := strconv.ParseBool(c.DefaultQuery("async", "false"))
 	if err != nil {
-		h.logger.Error("failed to fetch segmentation", "error", err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch segmentation data"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid async parameter"})
 		return
 	}
 
-	if len(segments) == 0 {
-		c.JSON(http.StatusOK, gin.H{"message": "no segmentation data to import"})
+	if async {
+		imp, err := h.importer.Start(c.Request.Context(), mode)
+		if errors.Is(err, importer.ErrAlreadyRunning) {
+			importConflict(c, imp)
+			return
+		}
+		if err != nil {
+			requestLogger(c, h.logger).Error("failed to start segmentation import", "error", err.Error())
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start segmentation import"})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":   "import started",
+			"import_id": imp.ID,
+			"mode":      imp.Mode,
+			"events":    fmt.Sprintf("/api/imports/%d/events", imp.ID),
+		})
 		return
 	}
 
-	if err := h.segmentationRepo.InsertOrUpdate(segments); err != nil {
-		h.logger.Error("failed to save segmentation data", "error", err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save segmentation data"})
+	imp, err := h.importer.Run(c.Request.Context(), mode)
+	if errors.Is(err, importer.ErrAlreadyRunning) {
+		importConflict(c, imp)
+		return
+	}
+	if err != nil {
+		resp := gin.H{"error": "segmentation import failed"}
+		if imp != nil {
+			resp["import_id"] = imp.ID
+		}
+		c.JSON(http.StatusInternalServerError, resp)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "import completed successfully",
-		"count":   len(segments),
+		"message":   "import completed successfully",
+		"import_id": imp.ID,
+		"mode":      imp.Mode,
+		"count":     imp.Fetched,
+		"rejected":  imp.Rejected,
+		"inserted":  imp.Inserted,
+		"updated":   imp.Updated,
+		"unchanged": imp.Unchanged,
 	})
 }
+
+// importConflict отвечает 409 на запуск импорта, пока выполняется другой
+func importConflict(c *gin.Context, running *models.Import) {
+	resp := gin.H{"error": "segmentation import is already running"}
+	if running != nil {
+		resp["import_id"] = running.ID
+		resp["events"] = fmt.Sprintf("/api/imports/%d/events", running.ID)
+	}
+	c.JSON(http.StatusConflict, resp)
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"go-test/internal/repository"
+)
+
+const (
+	defaultDeliveriesLimit = 100
+	maxDeliveriesLimit     = 1000
+)
+
+// WebhookHandler обрабатывает запросы к журналу доставки уведомлений
+type WebhookHandler struct {
+	logger      *slog.Logger
+	webhookRepo *repository.WebhookRepository
+}
+
+// NewWebhookHandler создает новый обработчик журнала доставки уведомлений
+func NewWebhookHandler(logger *slog.Logger, webhookRepo *repository.WebhookRepository) *WebhookHandler {
+	return &WebhookHandler{
+		logger:      logger,
+		webhookRepo: webhookRepo,
+	}
+}
+
+// GetDeliveries возвращает журнал доставки уведомлений
+// @Summary Получить журнал доставки уведомлений
+// @Description Возвращает последние попытки доставки webhooks, новые первыми
+// @Tags admin
+// @Produce json
+// @Security AdminToken
+// @Param event_id query string false "Только попытки доставки этого события"
+// @Param limit query int false "Количество записей (по умолчанию 100, не более 1000)"
+// @Success 200 {array} models.WebhookDelivery
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/webhooks/deliveries [get]
+func (h *WebhookHandler) GetDeliveries(c *gin.Context) {
+	limit := defaultDeliveriesLimit
+	if raw := c.Query("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil || limit <= 0 || limit > maxDeliveriesLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+	}
+
+	deliveries, err := h.webhookRepo.GetDeliveries(c.Query("event_id"), limit)
+	if err != nil {
+		requestLogger(c, h.logger).Error("failed to get webhook deliveries", "error", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get webhook deliveries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
@@ -0,0 +1,312 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go-test/internal/cache"
+	"go-test/internal/logutil"
+	"go-test/internal/models"
+	"go-test/internal/progress"
+	"go-test/internal/repository"
+	"go-test/internal/sap"
+	"go-test/internal/validation"
+	"go-test/internal/webhook"
+	"go-test/pkg/config"
+)
+
+// ErrAlreadyRunning - импорт уже выполняется этим или другим экземпляром
+// сервиса. Run и Start возвращают вместе с ней выполняющийся импорт, если
+// его удалось определить.
+var ErrAlreadyRunning = errors.New("segmentation import is already running")
+
+// Service выполняет импорт: загрузку из SAP, валидацию и сохранение в базу данных
+type Service struct {
+	mode                  models.ImportMode
+	fullReconcileInterval time.Duration
+
+	logger           *slog.Logger
+	sapClient        *sap.Client
+	validator        *validation.Validator
+	segmentationRepo *repository.SegmentationRepository
+	importRepo       *repository.ImportRepository
+	segmentRepo      *repository.SegmentRepository
+	statsRepo        *repository.StatsRepository
+	cache            *cache.Segmentation
+	notifier         *webhook.Notifier
+	hub              *progress.Hub
+}
+
+// NewService создает сервис импорта
+func NewService(
+	cfg *config.Config,
+	logger *slog.Logger,
+	sapClient *sap.Client,
+	validator *validation.Validator,
+	segmentationRepo *repository.SegmentationRepository,
+	importRepo *repository.ImportRepository,
+	segmentRepo *repository.SegmentRepository,
+	statsRepo *repository.StatsRepository,
+	cache *cache.Segmentation,
+	notifier *webhook.Notifier,
+	hub *progress.Hub,
+) *Service {
+	return &Service{
+		mode:                  models.ImportMode(cfg.Import.Mode),
+		fullReconcileInterval: cfg.Import.FullReconcileInterval,
+
+		logger:           logger,
+		sapClient:        sapClient,
+		validator:        validator,
+		segmentationRepo: segmentationRepo,
+		importRepo:       importRepo,
+		segmentRepo:      segmentRepo,
+		statsRepo:        statsRepo,
+		cache:            cache,
+		notifier:         notifier,
+		hub:              hub,
+	}
+}
+
+// Run выполняет полный цикл импорта и возвращает запись о нем.
+// Пустой requested означает режим из конфигурации. Одновременно выполняется
+// только один импорт (ErrAlreadyRunning).
+// Запись сохраняется в истории импортов и при ошибке. Идентификатор
+// запроса из ctx сохраняется в записи и передается в SAP; отмена ctx
+// импорт не прерывает.
+func (s *Service) Run(ctx context.Context, requested models.ImportMode) (*models.Import, error) {
+	// Импорт доводится до конца, даже если клиент разорвал соединение
+	ctx = context.WithoutCancel(ctx)
+
+	imp, ctx, release, err := s.begin(ctx, requested)
+	if err != nil {
+		return imp, err
+	}
+	defer release()
+
+	return imp, s.complete(ctx, imp)
+}
+
+// Start создает запись об импорте и выполняет его в фоне. Возвращается
+// копия записи на момент запуска; ход импорта доступен через progress.Hub.
+func (s *Service) Start(ctx context.Context, requested models.ImportMode) (*models.Import, error) {
+	ctx = context.WithoutCancel(ctx)
+
+	imp, ctx, release, err := s.begin(ctx, requested)
+	if err != nil {
+		return imp, err
+	}
+
+	started := *imp
+	go func() {
+		defer release()
+		// Результат и ошибки импорта логируются в complete
+		_ = s.complete(ctx, imp)
+	}()
+
+	return &started, nil
+}
+
+// begin берет блокировку импорта, выбирает режим, создает запись об
+// импорте и трекер его хода. release снимает блокировку после завершения
+// импорта. Если импорт уже выполняется, возвращается ErrAlreadyRunning и
+// выполняющийся импорт.
+func (s *Service) begin(ctx context.Context, requested models.ImportMode) (*models.Import, context.Context, func(), error) {
+	release, locked, err := s.importRepo.Lock(ctx)
+	if err != nil {
+		return nil, ctx, nil, err
+	}
+	if !locked {
+		running, err := s.importRepo.Running(ctx)
+		if err != nil {
+			s.logger.WarnContext(ctx, "failed to get running import", "error", err.Error())
+		}
+		return running, ctx, nil, ErrAlreadyRunning
+	}
+
+	// План строится под блокировкой, чтобы одновременные запуски не
+	// прочитали одну и ту же отметку high_water_mark
+	mode, since, err := s.plan(ctx, requested)
+	if err != nil {
+		release()
+		return nil, ctx, nil, err
+	}
+
+	imp, err := s.importRepo.Create(ctx, mode, since, logutil.RequestID(ctx))
+	if err != nil {
+		release()
+		return nil, ctx, nil, err
+	}
+
+	tracker := s.hub.Track(imp.ID)
+	tracker.Started(mode, since)
+
+	return imp, progress.WithTracker(ctx, tracker), release, nil
+}
+
+// complete выполняет импорт и сохраняет его итог
+func (s *Service) complete(ctx context.Context, imp *models.Import) error {
+	tracker := progress.FromContext(ctx)
+
+	logger := s.logger.With("import_id", imp.ID)
+	logger.InfoContext(ctx, "starting segmentation import", "mode", imp.Mode, "since", imp.Since)
+
+	if err := s.run(ctx, imp, logger); err != nil {
+		imp.Status = models.ImportStatusFailed
+		imp.Error = err.Error()
+		logger.ErrorContext(ctx, "segmentation import failed", "error", err.Error())
+
+		if finishErr := s.importRepo.Finish(ctx, imp); finishErr != nil {
+			logger.ErrorContext(ctx, "failed to save import status", "error", finishErr.Error())
+		}
+		tracker.Completed(imp)
+		s.notifier.Notify(ctx, webhook.EventImportFailed, imp)
+
+		return err
+	}
+
+	imp.Status = models.ImportStatusSucceeded
+	if err := s.importRepo.Finish(ctx, imp); err != nil {
+		imp.Status = models.ImportStatusFailed
+		imp.Error = err.Error()
+		tracker.Completed(imp)
+		return err
+	}
+
+	// Распределение по сегментам для статистики; импорт уже сохранен,
+	// поэтому ошибка только логируется
+	if err := s.statsRepo.Snapshot(ctx, imp.ID); err != nil {
+		logger.ErrorContext(ctx, "failed to save segment counts", "error", err.Error())
+	}
+
+	if imp.Inserted > 0 || imp.Updated > 0 {
+		s.cache.Refresh(ctx)
+	}
+
+	tracker.Completed(imp)
+	s.notifier.Notify(ctx, webhook.EventImportSucceeded, imp)
+
+	logger.InfoContext(ctx, "segmentation import completed",
+		"fetched", imp.Fetched,
+		"rejected", imp.Rejected,
+		"inserted", imp.Inserted,
+		"updated", imp.Updated,
+		"unchanged", imp.Unchanged,
+	)
+
+	return nil
+}
+
+// plan выбирает режим импорта. Инкрементальный импорт выполняется только
+// при известной отметке последнего изменения; если полный импорт давно не
+// запускался, выполняется полная сверка.
+func (s *Service) plan(ctx context.Context, requested models.ImportMode) (models.ImportMode, *time.Time, error) {
+	if requested == "" {
+		requested = s.mode
+	}
+
+	if requested != models.ImportModeIncremental {
+		return models.ImportModeFull, nil, nil
+	}
+
+	lastFull, err := s.importRepo.LastFullImportAt(ctx)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get last full import: %w", err)
+	}
+
+	if lastFull == nil || time.Since(*lastFull) >= s.fullReconcileInterval {
+		s.logger.InfoContext(ctx, "full reconciliation is due, running full import", "last_full_import", lastFull)
+		return models.ImportModeFull, nil, nil
+	}
+
+	mark, err := s.importRepo.LastHighWaterMark(ctx)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get high-water mark: %w", err)
+	}
+
+	if mark == nil {
+		s.logger.InfoContext(ctx, "no high-water mark yet, running full import")
+		return models.ImportModeFull, nil, nil
+	}
+
+	return models.ImportModeIncremental, mark, nil
+}
+
+func (s *Service) run(ctx context.Context, imp *models.Import, logger *slog.Logger) error {
+	var since time.Time
+	if imp.Since != nil {
+		since = *imp.Since
+	}
+
+	segments, err := s.sapClient.FetchSegmentation(ctx, since)
+	if err != nil {
+		return fmt.Errorf("failed to fetch segmentation data: %w", err)
+	}
+	imp.Fetched = len(segments)
+
+	valid, rejects := s.validator.Validate(segments)
+
+	known, err := s.segmentRepo.GetAll(ctx, false)
+	if err != nil {
+		return fmt.Errorf("failed to get segments: %w", err)
+	}
+	valid, register, segmentRejects := s.validator.CheckSegments(valid, known)
+	rejects = append(rejects, segmentRejects...)
+
+	// Отметка считается только по сохраняемым записям: отклоненная запись
+	// с более поздним временем изменения не сдвигает since следующего
+	// инкрементального импорта и будет запрошена снова после исправления в SAP
+	imp.HighWaterMark = highWaterMark(valid)
+
+	imp.Rejected = len(rejects)
+	progress.FromContext(ctx).Validated(len(valid), len(rejects))
+
+	if len(rejects) > 0 {
+		logger.WarnContext(ctx, "some segmentation records were rejected", "rejected", len(rejects))
+
+		if err := s.importRepo.SaveRejects(ctx, imp.ID, rejects); err != nil {
+			return fmt.Errorf("failed to save rejected records: %w", err)
+		}
+	}
+
+	if len(register) > 0 {
+		registered, err := s.segmentRepo.Register(ctx, register)
+		if err != nil {
+			return err
+		}
+		logger.InfoContext(ctx, "registered new segments", "count", registered)
+	}
+
+	if len(valid) == 0 {
+		logger.InfoContext(ctx, "no segmentation data to import")
+		return nil
+	}
+
+	result, changes, err := s.segmentationRepo.InsertOrUpdate(ctx, valid)
+	if err != nil {
+		return fmt.Errorf("failed to save segmentation data: %w", err)
+	}
+	imp.UpsertResult = result
+
+	// Изменения уже сохранены, поэтому о них сообщается независимо от
+	// дальнейшего исхода импорта
+	s.notifier.NotifyChanges(ctx, imp.ID, changes)
+
+	return nil
+}
+
+// highWaterMark возвращает максимальное время изменения среди сохраняемых
+// записей или nil, если SAP не передал его ни для одной из них
+func highWaterMark(segments []*models.Segmentation) *time.Time {
+	var mark *time.Time
+	for _, segment := range segments {
+		if segment.ChangedAt != nil && (mark == nil || segment.ChangedAt.After(*mark)) {
+			mark = segment.ChangedAt
+		}
+	}
+
+	return mark
+}
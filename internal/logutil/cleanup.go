@@ -1,61 +1,207 @@
 package logutil
 
 import (
+	"compress/gzip"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 )
 
-func CleanupOldLogs(logDir string, maxAgeInDays int, logger *slog.Logger) error {
-	if err := os.MkdirAll(logDir, 0755); err != nil {
-		return fmt.Errorf("failed to create log directory: %w", err)
+// backup - архивный файл логов
+type backup struct {
+	path string
+	// startedAt - начало периода, записанного в архив (из имени файла)
+	startedAt time.Time
+	// endedAt - время последней записи в архив (время изменения файла)
+	endedAt time.Time
+}
+
+// RunCleanup выполняет Cleanup сразу и затем каждые interval до отмены ctx
+func (w *RotatingWriter) RunCleanup(ctx context.Context, interval time.Duration, logger *slog.Logger) {
+	cleanup := func() {
+		removed, err := w.Cleanup()
+		if err != nil {
+			logger.Error("failed to cleanup old logs", "error", err.Error())
+			return
+		}
+		if removed > 0 {
+			logger.Info("log cleanup completed", "removed_files", removed)
+		}
+	}
+
+	cleanup()
+
+	if interval <= 0 {
+		return
 	}
 
-	now := time.Now()
-	cutoffTime := now.AddDate(0, 0, -maxAgeInDays)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
-	logger.Info("starting log cleanup",
-		"log_dir", logDir,
-		"max_age_days", maxAgeInDays,
-		"cutoff_time", cutoffTime.Format(time.RFC3339),
-	)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cleanup()
+		}
+	}
+}
+
+// Cleanup сжимает несжатые архивные файлы и удаляет лишние по MaxBackups
+// и устаревшие по MaxAge. Возраст архива отсчитывается от последней записи
+// в него, а не от начала периода, поэтому свежий архив долго открытого
+// файла не удаляется сразу после ротации. Активный файл не затрагивается. Возвращает
+// количество удаленных файлов.
+func (w *RotatingWriter) Cleanup() (int, error) {
+	w.cleanupMu.Lock()
+	defer w.cleanupMu.Unlock()
 
-	files, err := os.ReadDir(logDir)
+	backups, err := w.backups()
 	if err != nil {
-		return fmt.Errorf("failed to read log directory: %w", err)
+		return 0, err
 	}
 
-	var removedCount int
+	var errs []error
 
-	for _, file := range files {
-		if file.IsDir() {
-			continue
+	if w.opts.Compress {
+		for i, b := range backups {
+			if strings.HasSuffix(b.path, ".gz") {
+				continue
+			}
+
+			compressed, err := compressFile(b.path)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			backups[i].path = compressed
 		}
+	}
 
-		filePath := filepath.Join(logDir, file.Name())
+	// Новые архивы первыми
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].startedAt.After(backups[j].startedAt)
+	})
 
-		fileInfo, err := file.Info()
-		if err != nil {
-			logger.Error("failed to get file info", "file", filePath, "error", err.Error())
+	cutoff := time.Now().Add(-w.opts.MaxAge)
+	removed := 0
+
+	for i, b := range backups {
+		tooMany := w.opts.MaxBackups > 0 && i >= w.opts.MaxBackups
+		tooOld := w.opts.MaxAge > 0 && b.endedAt.Before(cutoff)
+		if !tooMany && !tooOld {
+			continue
+		}
+
+		if err := os.Remove(b.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("failed to remove log file: %w", err))
 			continue
 		}
+		removed++
+	}
 
-		if fileInfo.ModTime().Before(cutoffTime) {
-			logger.Info("removing old log file",
-				"file", filePath,
-				"mod_time", fileInfo.ModTime().Format(time.RFC3339),
-			)
+	return removed, errors.Join(errs...)
+}
+
+// backups возвращает архивные файлы активного файла логов
+func (w *RotatingWriter) backups() ([]backup, error) {
+	dir := filepath.Dir(w.path)
+	ext := filepath.Ext(w.path)
+	prefix := strings.TrimSuffix(filepath.Base(w.path), ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log directory: %w", err)
+	}
+
+	var backups []backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
 
-			if err := os.Remove(filePath); err != nil {
-				logger.Error("failed to remove log file", "file", filePath, "error", err.Error())
-			} else {
-				removedCount++
+		stamp := strings.TrimPrefix(name, prefix)
+		stamp, ok := strings.CutSuffix(stamp, ext+".gz")
+		if !ok {
+			if stamp, ok = strings.CutSuffix(stamp, ext); !ok {
+				continue
 			}
 		}
+
+		startedAt, err := time.ParseInLocation(backupTimeFormat, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			// Архив удален другим процессом
+			continue
+		}
+
+		backups = append(backups, backup{
+			path:      filepath.Join(dir, name),
+			startedAt: startedAt,
+			endedAt:   info.ModTime(),
+		})
+	}
+
+	return backups, nil
+}
+
+// compressFile сжимает файл в path.gz и удаляет исходный. Сжатый файл
+// получает время изменения исходного, от которого отсчитывается его возраст.
+func compressFile(path string) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open log file for compression: %w", err)
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat log file for compression: %w", err)
+	}
+
+	target := path + ".gz"
+	dst, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to create compressed log file: %w", err)
+	}
+
+	zw := gzip.NewWriter(dst)
+	zw.Name = filepath.Base(path)
+
+	_, err = io.Copy(zw, src)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(target)
+		return "", fmt.Errorf("failed to compress log file: %w", err)
+	}
+
+	if err := os.Chtimes(target, info.ModTime(), info.ModTime()); err != nil {
+		os.Remove(target)
+		return "", fmt.Errorf("failed to set compressed log file time: %w", err)
+	}
+
+	src.Close()
+	if err := os.Remove(path); err != nil {
+		return "", fmt.Errorf("failed to remove compressed log file: %w", err)
 	}
 
-	logger.Info("log cleanup completed", "removed_files", removedCount)
-	return nil
+	return target, nil
 }
@@ -0,0 +1,44 @@
+package logutil
+
+import (
+	"context"
+	"log/slog"
+)
+
+const (
+	// RequestIDKey - имя атрибута с идентификатором запроса
+	RequestIDKey = "request_id"
+	// RequestIDHeader - HTTP-заголовок с идентификатором запроса
+	RequestIDHeader = "X-Request-ID"
+)
+
+type requestIDKey struct{}
+
+type loggerKey struct{}
+
+// WithRequestID сохраняет идентификатор запроса в контексте. Логгеры,
+// созданные New и Levels.Logger, добавляют его к записям, сделанным
+// методами *Context.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID возвращает идентификатор запроса из контекста или пустую строку
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithLogger сохраняет логгер запроса в контексте
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext возвращает логгер запроса из контекста или fallback
+func FromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+
+	return fallback
+}
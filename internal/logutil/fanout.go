@@ -0,0 +1,75 @@
+package logutil
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// sink - выход логов со своим минимальным уровнем
+type sink struct {
+	handler slog.Handler
+	level   slog.Level
+}
+
+// newSink создает выход с минимальным уровнем level; пустой level -
+// без ограничения. Значение проверяется при загрузке конфигурации.
+func newSink(handler slog.Handler, level string) sink {
+	s := sink{handler: handler, level: passAll}
+	if level != "" {
+		_ = s.level.UnmarshalText([]byte(level))
+	}
+
+	return s
+}
+
+func (s sink) enabled(ctx context.Context, level slog.Level) bool {
+	return level >= s.level && s.handler.Enabled(ctx, level)
+}
+
+// fanoutHandler передает запись во все выходы, уровень которых она проходит.
+// Ошибка одного выхода не мешает записи в остальные.
+type fanoutHandler struct {
+	sinks []sink
+}
+
+func (h *fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, s := range h.sinks {
+		if s.enabled(ctx, level) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h *fanoutHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, s := range h.sinks {
+		if !s.enabled(ctx, r.Level) {
+			continue
+		}
+		if err := s.handler.Handle(ctx, r.Clone()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (h *fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.each(func(handler slog.Handler) slog.Handler { return handler.WithAttrs(attrs) })
+}
+
+func (h *fanoutHandler) WithGroup(name string) slog.Handler {
+	return h.each(func(handler slog.Handler) slog.Handler { return handler.WithGroup(name) })
+}
+
+func (h *fanoutHandler) each(f func(slog.Handler) slog.Handler) *fanoutHandler {
+	sinks := make([]sink, len(h.sinks))
+	for i, s := range h.sinks {
+		sinks[i] = sink{handler: f(s.handler), level: s.level}
+	}
+
+	return &fanoutHandler{sinks: sinks}
+}
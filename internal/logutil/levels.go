@@ -0,0 +1,170 @@
+package logutil
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"sync"
+
+	"go-test/pkg/config"
+)
+
+// Компоненты приложения с отдельно настраиваемым уровнем логирования
+const (
+	ComponentSAP        = "sap"
+	ComponentRepository = "repository"
+	ComponentHTTP       = "http"
+)
+
+// Components перечисляет компоненты, для которых можно задать свой уровень
+var Components = []string{ComponentSAP, ComponentRepository, ComponentHTTP}
+
+// passAll - уровень внутренних обработчиков: фильтрацию выполняет levelHandler
+const passAll = slog.Level(math.MinInt32)
+
+// Levels хранит общий уровень логирования и переопределения для компонентов.
+// Уровни можно менять во время работы; компонент без переопределения
+// использует общий уровень.
+type Levels struct {
+	global slog.LevelVar
+
+	mu        sync.RWMutex
+	overrides map[string]slog.Level
+}
+
+// NewLevels создает набор уровней по конфигурации
+func NewLevels(cfg *config.Config) *Levels {
+	l := &Levels{overrides: make(map[string]slog.Level)}
+	l.Apply(cfg)
+
+	return l
+}
+
+// Apply устанавливает уровни из конфигурации. Переопределения, заданные
+// во время работы, заменяются значениями из конфигурации.
+func (l *Levels) Apply(cfg *config.Config) {
+	l.global.Set(cfg.LogLevel())
+
+	for component, value := range cfg.Log.Components() {
+		var level *slog.Level
+		if value != "" {
+			var parsed slog.Level
+			if err := parsed.UnmarshalText([]byte(value)); err == nil {
+				level = &parsed
+			}
+		}
+		_ = l.SetComponent(component, level)
+	}
+}
+
+// Global возвращает общий уровень
+func (l *Levels) Global() slog.Level {
+	return l.global.Level()
+}
+
+// SetGlobal меняет общий уровень
+func (l *Levels) SetGlobal(level slog.Level) {
+	l.global.Set(level)
+}
+
+// Level возвращает действующий уровень компонента
+func (l *Levels) Level(component string) slog.Level {
+	l.mu.RLock()
+	level, ok := l.overrides[component]
+	l.mu.RUnlock()
+
+	if ok {
+		return level
+	}
+
+	return l.global.Level()
+}
+
+// SetComponent задает уровень компонента. nil сбрасывает переопределение,
+// и компонент снова использует общий уровень.
+func (l *Levels) SetComponent(component string, level *slog.Level) error {
+	if !slices.Contains(Components, component) {
+		return fmt.Errorf("unknown log component %q", component)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if level == nil {
+		delete(l.overrides, component)
+	} else {
+		l.overrides[component] = *level
+	}
+
+	return nil
+}
+
+// Overrides возвращает переопределенные уровни компонентов
+func (l *Levels) Overrides() map[string]slog.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	overrides := make(map[string]slog.Level, len(l.overrides))
+	for component, level := range l.overrides {
+		overrides[component] = level
+	}
+
+	return overrides
+}
+
+// Logger возвращает логгер компонента: записи фильтруются по его уровню и
+// помечаются атрибутом component
+func (l *Levels) Logger(base *slog.Logger, component string) *slog.Logger {
+	handler := base.Handler()
+	if lh, ok := handler.(*levelHandler); ok {
+		handler = lh.inner
+	}
+
+	return slog.New(&levelHandler{inner: handler, levels: l, component: component}).
+		With("component", component)
+}
+
+// levelHandler пропускает записи не ниже уровня компонента; пустой
+// component соответствует общему уровню. Если в контексте записи есть
+// идентификатор запроса, он добавляется атрибутом request_id.
+type levelHandler struct {
+	inner     slog.Handler
+	levels    *Levels
+	component string
+	// hasRequestID - request_id уже добавлен через With
+	hasRequestID bool
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.levels.Level(h.component) && h.inner.Enabled(ctx, level)
+}
+
+func (h *levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" && !h.hasRequestID {
+		r = r.Clone()
+		r.AddAttrs(slog.String(RequestIDKey, id))
+	}
+
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.inner = h.inner.WithAttrs(attrs)
+	for _, a := range attrs {
+		if a.Key == RequestIDKey {
+			h2.hasRequestID = true
+		}
+	}
+
+	return &h2
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	h2 := *h
+	h2.inner = h.inner.WithGroup(name)
+
+	return &h2
+}
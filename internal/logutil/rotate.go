@@ -0,0 +1,240 @@
+package logutil
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat - формат времени начала периода в имени архивного файла
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// maxFirstRecordSize ограничивает чтение первой записи файла логов
+const maxFirstRecordSize = 64 << 10
+
+// RotateOptions задает правила ротации файла логов
+type RotateOptions struct {
+	// MaxSize - размер файла в байтах, после которого он ротируется; 0 - без ограничения
+	MaxSize int64
+	// Daily включает ротацию при смене суток
+	Daily bool
+	// MaxBackups - сколько архивных файлов хранить; 0 - без ограничения
+	MaxBackups int
+	// MaxAge - сколько хранить архивные файлы после последней записи в них;
+	// 0 - без ограничения
+	MaxAge time.Duration
+	// Compress включает сжатие архивных файлов gzip
+	Compress bool
+}
+
+// RotatingWriter пишет в файл и ротирует его по размеру и/или при смене
+// суток. Архивные файлы получают в имени время начала записанного в них
+// периода (segmentation_import-2006-01-02T15-04-05.000.log), сжимаются и
+// удаляются в фоне по количеству и возрасту, отсчитываемому от последней
+// записи в архив.
+type RotatingWriter struct {
+	path string
+	opts RotateOptions
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+
+	cleanupMu sync.Mutex
+	wg        sync.WaitGroup
+}
+
+// NewRotatingWriter открывает файл path на дозапись
+func NewRotatingWriter(path string, opts RotateOptions) (*RotatingWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	w := &RotatingWriter{path: path, opts: opts}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Write записывает p, предварительно ротируя файл, если это требуется
+func (w *RotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if w.rotationDue(int64(len(p)), time.Now()) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+
+	return n, err
+}
+
+// Rotate принудительно ротирует файл
+func (w *RotatingWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return os.ErrClosed
+	}
+
+	return w.rotate()
+}
+
+// Close закрывает файл и дожидается фоновой обработки архивов
+func (w *RotatingWriter) Close() error {
+	w.mu.Lock()
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.mu.Unlock()
+
+	w.wg.Wait()
+
+	return err
+}
+
+func (w *RotatingWriter) open() error {
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	w.file = file
+	w.size = info.Size()
+	w.openedAt = time.Now()
+	if w.size > 0 {
+		// Непустой файл мог остаться с прошлых суток: период начался с его
+		// первой записи
+		w.openedAt = firstRecordTime(w.path, info.ModTime())
+	}
+
+	return nil
+}
+
+// firstRecordTime возвращает время первой записи файла логов в формате json
+// или logfmt. Если его не удалось прочитать (формат pretty не содержит
+// даты), возвращается fallback.
+func firstRecordTime(path string, fallback time.Time) time.Time {
+	file, err := os.Open(path)
+	if err != nil {
+		return fallback
+	}
+	defer file.Close()
+
+	line, err := bufio.NewReader(io.LimitReader(file, maxFirstRecordSize)).ReadString('\n')
+	if err != nil && line == "" {
+		return fallback
+	}
+
+	var record struct {
+		Time time.Time `json:"time"`
+	}
+	if json.Unmarshal([]byte(line), &record) == nil && !record.Time.IsZero() {
+		return record.Time
+	}
+
+	for _, field := range strings.Fields(line) {
+		if value, ok := strings.CutPrefix(field, slog.TimeKey+"="); ok {
+			if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+				return t
+			}
+			break
+		}
+	}
+
+	return fallback
+}
+
+func (w *RotatingWriter) rotationDue(n int64, now time.Time) bool {
+	if w.size == 0 {
+		return false
+	}
+
+	if w.opts.MaxSize > 0 && w.size+n > w.opts.MaxSize {
+		return true
+	}
+
+	if w.opts.Daily {
+		y1, m1, d1 := w.openedAt.Date()
+		y2, m2, d2 := now.Date()
+		return y1 != y2 || m1 != m2 || d1 != d2
+	}
+
+	return false
+}
+
+// rotate переименовывает текущий файл в архивный и открывает новый.
+// Сжатие и удаление старых архивов выполняются в фоне.
+func (w *RotatingWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	w.file = nil
+
+	if err := os.Rename(w.path, w.backupName(w.openedAt)); err != nil {
+		return fmt.Errorf("failed to rename log file: %w", err)
+	}
+
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		if _, err := w.Cleanup(); err != nil {
+			// Писать в лог нельзя: ошибка могла возникнуть при его же ротации
+			fmt.Fprintf(os.Stderr, "log cleanup after rotation failed: %v\n", err)
+		}
+	}()
+
+	return nil
+}
+
+// backupName возвращает имя архивного файла, открытого в момент t. Если
+// такой архив уже есть (файлы открыты в одну миллисекунду), время
+// сдвигается, чтобы не перезаписать его.
+func (w *RotatingWriter) backupName(t time.Time) string {
+	ext := filepath.Ext(w.path)
+	base := strings.TrimSuffix(w.path, ext)
+
+	for {
+		name := base + "-" + t.Format(backupTimeFormat) + ext
+		if !exists(name) && !exists(name+".gz") {
+			return name
+		}
+		t = t.Add(time.Millisecond)
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
@@ -0,0 +1,93 @@
+package logutil
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// samplingSlots - размер таблицы счетчиков. Сообщения с совпавшим хешем
+// делят счетчик, зато память не растет с числом разных сообщений.
+const samplingSlots = 4096
+
+// samplingHandler прореживает debug-записи: за каждый интервал записи
+// с одним сообщением пропускаются первые initial, затем каждая thereafter-я.
+// Записи уровня info и выше проходят всегда.
+type samplingHandler struct {
+	inner   slog.Handler
+	sampler *sampler
+}
+
+type sampler struct {
+	initial    int
+	thereafter int
+	interval   time.Duration
+
+	mu    sync.Mutex
+	slots [samplingSlots]samplingCounter
+}
+
+type samplingCounter struct {
+	resetAt time.Time
+	count   int
+}
+
+func newSamplingHandler(inner slog.Handler, initial, thereafter int, interval time.Duration) *samplingHandler {
+	return &samplingHandler{
+		inner: inner,
+		sampler: &sampler{
+			initial:    initial,
+			thereafter: max(thereafter, 1),
+			interval:   interval,
+		},
+	}
+}
+
+func (h *samplingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *samplingHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < slog.LevelInfo && !h.sampler.allow(r.Message, r.Time) {
+		return nil
+	}
+
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *samplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &samplingHandler{inner: h.inner.WithAttrs(attrs), sampler: h.sampler}
+}
+
+func (h *samplingHandler) WithGroup(name string) slog.Handler {
+	return &samplingHandler{inner: h.inner.WithGroup(name), sampler: h.sampler}
+}
+
+// allow считает запись с сообщением msg и решает, пропускать ли ее
+func (s *sampler) allow(msg string, now time.Time) bool {
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(msg))
+	slot := hash.Sum32() % samplingSlots
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := &s.slots[slot]
+	if !now.Before(c.resetAt) {
+		c.resetAt = now.Add(s.interval)
+		c.count = 0
+	}
+	c.count++
+
+	if c.count <= s.initial {
+		return true
+	}
+
+	return (c.count-s.initial)%s.thereafter == 0
+}
@@ -1,48 +1,161 @@
 package logutil
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"time"
 
+	"go-test/pkg/config"
 	"go-test/pkg/logger/slogpretty"
 )
 
-func SetupLogger(env, logDir, logFileName string) (*slog.Logger, error) {
-	if err := os.MkdirAll(logDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create log directory: %w", err)
+// Форматы записей
+const (
+	FormatPretty = "pretty"
+	FormatJSON   = "json"
+	FormatLogfmt = "logfmt"
+)
+
+// Выходы логов
+const (
+	OutputStdout   = "stdout"
+	OutputFile     = "file"
+	OutputRotating = "rotating"
+	OutputSyslog   = "syslog"
+)
+
+// Logger - логгер приложения вместе с открытыми для него выходами.
+// Записи проходят общий уровень и уровни компонентов (Levels), сэмплирование
+// debug-записей и рассылаются во все выходы, у каждого из которых свой
+// формат и минимальный уровень.
+type Logger struct {
+	*slog.Logger
+
+	rotating        *RotatingWriter
+	cleanupInterval time.Duration
+	closers         []io.Closer
+}
+
+// New создает логгер по разделу Log конфигурации. Уровни задаются levels
+// и могут меняться во время работы; логгеры компонентов создаются через
+// levels.Logger. Выходы закрываются методом Close.
+func New(cfg *config.Config, levels *Levels) (*Logger, error) {
+	l := &Logger{cleanupInterval: cfg.Log.CleanupInterval}
+
+	defaultFormat := cfg.Log.Format
+	if defaultFormat == "" {
+		defaultFormat = FormatJSON
+		if cfg.Env == config.EnvLocal {
+			defaultFormat = FormatPretty
+		}
+	}
+	formatOr := func(format string) string {
+		if format == "" {
+			return defaultFormat
+		}
+		return format
 	}
 
-	logFilePath := filepath.Join(logDir, logFileName)
-	logFile, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open log file: %w", err)
+	var sinks []sink
+	for _, output := range cfg.Log.Outputs {
+		switch output {
+		case OutputStdout:
+			sinks = append(sinks, newSink(newFormatHandler(defaultFormat, os.Stdout), cfg.Log.StdoutLevel))
+
+		case OutputFile:
+			if err := os.MkdirAll(filepath.Dir(cfg.Log.File), 0755); err != nil {
+				l.Close()
+				return nil, fmt.Errorf("failed to create log directory: %w", err)
+			}
+			file, err := os.OpenFile(cfg.Log.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				l.Close()
+				return nil, fmt.Errorf("failed to open log file: %w", err)
+			}
+			l.closers = append(l.closers, file)
+			sinks = append(sinks, newSink(newFormatHandler(formatOr(cfg.Log.FileFormat), file), cfg.Log.FileLevel))
+
+		case OutputRotating:
+			w, err := NewRotatingWriter(cfg.Log.File, RotateOptions{
+				MaxSize:    int64(cfg.Log.MaxSizeMB) << 20,
+				Daily:      cfg.Log.RotateDaily,
+				MaxBackups: cfg.Log.MaxBackups,
+				MaxAge:     time.Duration(cfg.Log.MaxAgeDays) * 24 * time.Hour,
+				Compress:   cfg.Log.Compress,
+			})
+			if err != nil {
+				l.Close()
+				return nil, err
+			}
+			l.rotating = w
+			l.closers = append(l.closers, w)
+			sinks = append(sinks, newSink(newFormatHandler(formatOr(cfg.Log.FileFormat), w), cfg.Log.FileLevel))
+
+		case OutputSyslog:
+			w, err := newSyslogWriter(cfg.Log.SyslogAddr, cfg.Log.SyslogTag)
+			if err != nil {
+				l.Close()
+				return nil, err
+			}
+			l.closers = append(l.closers, w)
+			handler := &syslogHandler{inner: newFormatHandler(formatOr(cfg.Log.SyslogFormat), w), w: w}
+			sinks = append(sinks, newSink(handler, cfg.Log.SyslogLevel))
+
+		default:
+			l.Close()
+			return nil, fmt.Errorf("unknown log output: %q", output)
+		}
+	}
+
+	var handler slog.Handler = &fanoutHandler{sinks: sinks}
+	if cfg.Log.SamplingInitial > 0 {
+		handler = newSamplingHandler(handler, cfg.Log.SamplingInitial, cfg.Log.SamplingThereafter, cfg.Log.SamplingInterval)
 	}
 
-	mw := io.MultiWriter(os.Stdout, logFile)
+	l.Logger = slog.New(&levelHandler{inner: handler, levels: levels})
+
+	return l, nil
+}
+
+// RunCleanup обслуживает архивы выхода rotating до отмены ctx
+// (см. RotatingWriter.RunCleanup). Без выхода rotating сразу возвращается.
+func (l *Logger) RunCleanup(ctx context.Context) {
+	if l.rotating == nil {
+		return
+	}
 
-	var logger *slog.Logger
+	l.rotating.RunCleanup(ctx, l.cleanupInterval, l.Logger)
+}
 
-	switch env {
-	case "local":
-		opts := slogpretty.PrettyHandlerOptions{
-			SlogOpts: &slog.HandlerOptions{
-				Level: slog.LevelDebug,
-			},
+// Close закрывает файлы и соединения выходов
+func (l *Logger) Close() error {
+	var errs []error
+	for _, c := range l.closers {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
 		}
-		handler := opts.NewPrettyHandler(mw)
-		logger = slog.New(handler)
-	case "dev":
-		logger = slog.New(slog.NewJSONHandler(mw, &slog.HandlerOptions{
-			Level: slog.LevelDebug,
-		}))
-	default:
-		logger = slog.New(slog.NewJSONHandler(mw, &slog.HandlerOptions{
-			Level: slog.LevelInfo,
-		}))
 	}
+	l.closers = nil
+
+	return errors.Join(errs...)
+}
 
-	return logger, nil
+// newFormatHandler создает обработчик формата format без собственной
+// фильтрации по уровню
+func newFormatHandler(format string, w io.Writer) slog.Handler {
+	opts := &slog.HandlerOptions{Level: passAll}
+
+	switch format {
+	case FormatPretty:
+		return slogpretty.PrettyHandlerOptions{SlogOpts: opts}.NewPrettyHandler(w)
+	case FormatLogfmt:
+		return slog.NewTextHandler(w, opts)
+	default:
+		return slog.NewJSONHandler(w, opts)
+	}
 }
@@ -0,0 +1,127 @@
+package logutil
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// syslogFacility - facility user (RFC 5424)
+const syslogFacility = 1
+
+// maxDatagramSize - наибольший размер UDP-пакета; длинные записи обрезаются
+const maxDatagramSize = 65507
+
+// syslogWriter отправляет каждую запись отдельным UDP-пакетом в формате
+// RFC 5424. Важность (severity) задает syslogHandler перед записью.
+type syslogWriter struct {
+	conn     net.Conn
+	hostname string
+	tag      string
+	pid      string
+
+	// mu удерживается syslogHandler на время записи вместе с severity -
+	// важностью текущей записи
+	mu       sync.Mutex
+	severity int
+}
+
+func newSyslogWriter(addr, tag string) (*syslogWriter, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	if tag == "" {
+		tag = "-"
+	}
+
+	return &syslogWriter{
+		conn:     conn,
+		hostname: hostname,
+		tag:      tag,
+		pid:      strconv.Itoa(os.Getpid()),
+	}, nil
+}
+
+// Write отправляет p как сообщение одной записи
+func (w *syslogWriter) Write(p []byte) (int, error) {
+	var buf bytes.Buffer
+	// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+	fmt.Fprintf(&buf, "<%d>1 %s %s %s %s - - ",
+		syslogFacility*8+w.severity,
+		time.Now().Format(time.RFC3339Nano),
+		w.hostname,
+		w.tag,
+		w.pid,
+	)
+	buf.Write(bytes.TrimRight(p, "\n"))
+
+	msg := buf.Bytes()
+	if len(msg) > maxDatagramSize {
+		msg = msg[:maxDatagramSize]
+	}
+
+	if _, err := w.conn.Write(msg); err != nil {
+		return 0, fmt.Errorf("failed to write to syslog: %w", err)
+	}
+
+	return len(p), nil
+}
+
+func (w *syslogWriter) Close() error {
+	return w.conn.Close()
+}
+
+// syslogHandler передает важность записи в syslogWriter. Внутренний
+// обработчик пишет запись одним вызовом Write, поэтому достаточно
+// выставить важность перед ним под общей блокировкой.
+type syslogHandler struct {
+	inner slog.Handler
+	w     *syslogWriter
+}
+
+func (h *syslogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *syslogHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.w.mu.Lock()
+	defer h.w.mu.Unlock()
+
+	h.w.severity = syslogSeverity(r.Level)
+
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *syslogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &syslogHandler{inner: h.inner.WithAttrs(attrs), w: h.w}
+}
+
+func (h *syslogHandler) WithGroup(name string) slog.Handler {
+	return &syslogHandler{inner: h.inner.WithGroup(name), w: h.w}
+}
+
+// syslogSeverity сопоставляет уровень slog важности syslog
+func syslogSeverity(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return 3 // err
+	case level >= slog.LevelWarn:
+		return 4 // warning
+	case level >= slog.LevelInfo:
+		return 6 // info
+	default:
+		return 7 // debug
+	}
+}
@@ -0,0 +1,345 @@
+// Package metrics содержит минимальную реализацию метрик в текстовом
+// формате Prometheus без внешних зависимостей.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+type metricType string
+
+const (
+	typeCounter metricType = "counter"
+	typeGauge   metricType = "gauge"
+)
+
+// collector - метрика, которую можно вывести в формате Prometheus
+type collector interface {
+	name() string
+	write(w io.Writer)
+}
+
+// Registry хранит зарегистрированные метрики
+type Registry struct {
+	mu         sync.Mutex
+	collectors map[string]collector
+}
+
+// NewRegistry создает пустой реестр метрик
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+// DefaultRegistry - реестр, в котором регистрируются метрики приложения
+var DefaultRegistry = NewRegistry()
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.collectors[c.name()]; ok {
+		panic(fmt.Sprintf("metric %q is already registered", c.name()))
+	}
+	r.collectors[c.name()] = c
+}
+
+// Write выводит все метрики в текстовом формате Prometheus
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	collectors := make([]collector, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		collectors = append(collectors, r.collectors[name])
+	}
+	r.mu.Unlock()
+
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// Handler возвращает HTTP-обработчик для отдачи метрик реестра
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	})
+}
+
+// Handler возвращает HTTP-обработчик для DefaultRegistry
+func Handler() http.Handler {
+	return DefaultRegistry.Handler()
+}
+
+// value хранит float64 с атомарным доступом
+type value struct {
+	bits atomic.Uint64
+}
+
+func (v *value) Load() float64 {
+	return math.Float64frombits(v.bits.Load())
+}
+
+func (v *value) Store(f float64) {
+	v.bits.Store(math.Float64bits(f))
+}
+
+func (v *value) Add(delta float64) {
+	for {
+		old := v.bits.Load()
+		next := math.Float64bits(math.Float64frombits(old) + delta)
+		if v.bits.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
+
+// Counter - монотонно возрастающий счетчик
+type Counter struct {
+	v value
+}
+
+// Inc увеличивает счетчик на 1
+func (c *Counter) Inc() {
+	c.v.Add(1)
+}
+
+// Add увеличивает счетчик на delta, отрицательные значения игнорируются
+func (c *Counter) Add(delta float64) {
+	if delta > 0 {
+		c.v.Add(delta)
+	}
+}
+
+// Value возвращает текущее значение счетчика
+func (c *Counter) Value() float64 {
+	return c.v.Load()
+}
+
+// Gauge - значение, которое может как расти, так и уменьшаться
+type Gauge struct {
+	v value
+}
+
+// Set устанавливает значение
+func (g *Gauge) Set(f float64) {
+	g.v.Store(f)
+}
+
+// Add изменяет значение на delta
+func (g *Gauge) Add(delta float64) {
+	g.v.Add(delta)
+}
+
+// Value возвращает текущее значение
+func (g *Gauge) Value() float64 {
+	return g.v.Load()
+}
+
+// vec - семейство метрик одного имени с разными значениями меток
+type vec[T any] struct {
+	metricName string
+	help       string
+	typ        metricType
+	labels     []string
+	load       func(*T) float64
+
+	mu     sync.Mutex
+	series map[string]*T
+	values map[string][]string
+}
+
+func newVec[T any](name, help string, typ metricType, labels []string, load func(*T) float64) *vec[T] {
+	return &vec[T]{
+		metricName: name,
+		help:       help,
+		typ:        typ,
+		labels:     labels,
+		load:       load,
+		series:     make(map[string]*T),
+		values:     make(map[string][]string),
+	}
+}
+
+func (v *vec[T]) with(labelValues ...string) *T {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metric %q expects %d label values, got %d", v.metricName, len(v.labels), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	m, ok := v.series[key]
+	if !ok {
+		m = new(T)
+		v.series[key] = m
+		v.values[key] = append([]string(nil), labelValues...)
+	}
+
+	return m
+}
+
+func (v *vec[T]) name() string {
+	return v.metricName
+}
+
+func (v *vec[T]) write(w io.Writer) {
+	writeHeader(w, v.metricName, v.help, v.typ)
+
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		writeSample(w, v.metricName, v.labels, v.values[key], v.load(v.series[key]))
+	}
+	v.mu.Unlock()
+}
+
+// CounterVec - счетчики с метками
+type CounterVec struct {
+	*vec[Counter]
+}
+
+// With возвращает счетчик для указанных значений меток
+func (c *CounterVec) With(labelValues ...string) *Counter {
+	return c.with(labelValues...)
+}
+
+// GaugeVec - значения с метками
+type GaugeVec struct {
+	*vec[Gauge]
+}
+
+// With возвращает значение для указанных значений меток
+func (g *GaugeVec) With(labelValues ...string) *Gauge {
+	return g.with(labelValues...)
+}
+
+// gaugeFunc вычисляет значение при каждом выводе метрик
+type gaugeFunc struct {
+	metricName string
+	help       string
+	fn         func() float64
+}
+
+func (g *gaugeFunc) name() string {
+	return g.metricName
+}
+
+func (g *gaugeFunc) write(w io.Writer) {
+	writeHeader(w, g.metricName, g.help, typeGauge)
+	writeSample(w, g.metricName, nil, nil, g.fn())
+}
+
+// NewCounter регистрирует счетчик без меток
+func (r *Registry) NewCounter(name, help string) *Counter {
+	return r.NewCounterVec(name, help).With()
+}
+
+// NewCounterVec регистрирует семейство счетчиков с метками
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{newVec(name, help, typeCounter, labels, (*Counter).Value)}
+	r.register(c)
+
+	return c
+}
+
+// NewGauge регистрирует значение без меток
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	return r.NewGaugeVec(name, help).With()
+}
+
+// NewGaugeVec регистрирует семейство значений с метками
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{newVec(name, help, typeGauge, labels, (*Gauge).Value)}
+	r.register(g)
+
+	return g
+}
+
+// NewGaugeFunc регистрирует значение, вычисляемое функцией fn при выводе
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(&gaugeFunc{metricName: name, help: help, fn: fn})
+}
+
+// NewCounter регистрирует счетчик в DefaultRegistry
+func NewCounter(name, help string) *Counter {
+	return DefaultRegistry.NewCounter(name, help)
+}
+
+// NewCounterVec регистрирует семейство счетчиков в DefaultRegistry
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return DefaultRegistry.NewCounterVec(name, help, labels...)
+}
+
+// NewGauge регистрирует значение в DefaultRegistry
+func NewGauge(name, help string) *Gauge {
+	return DefaultRegistry.NewGauge(name, help)
+}
+
+// NewGaugeVec регистрирует семейство значений в DefaultRegistry
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return DefaultRegistry.NewGaugeVec(name, help, labels...)
+}
+
+// NewGaugeFunc регистрирует вычисляемое значение в DefaultRegistry
+func NewGaugeFunc(name, help string, fn func() float64) {
+	DefaultRegistry.NewGaugeFunc(name, help, fn)
+}
+
+func writeHeader(w io.Writer, name, help string, typ metricType) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, escapeHelp(help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+}
+
+func writeSample(w io.Writer, name string, labels, values []string, v float64) {
+	var b strings.Builder
+	b.WriteString(name)
+
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(&b, "%s=%q", label, values[i])
+		}
+		b.WriteByte('}')
+	}
+
+	fmt.Fprintf(w, "%s %s\n", b.String(), formatFloat(v))
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+
+	return fmt.Sprintf("%g", v)
+}
+
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
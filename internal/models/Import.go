@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// ImportStatus описывает состояние запуска импорта
+type ImportStatus string
+
+const (
+	ImportStatusRunning   ImportStatus = "running"
+	ImportStatusSucceeded ImportStatus = "succeeded"
+	ImportStatusFailed    ImportStatus = "failed"
+)
+
+// ImportMode определяет, загружается ли весь набор данных или только изменения
+type ImportMode string
+
+const (
+	ImportModeFull        ImportMode = "full"
+	ImportModeIncremental ImportMode = "incremental"
+)
+
+// Import содержит историю одного запуска импорта
+type Import struct {
+	ID            int64        `json:"id" db:"id"`
+	Status        ImportStatus `json:"status" db:"status"`
+	Mode          ImportMode   `json:"mode" db:"mode"`
+	Since         *time.Time   `json:"since,omitempty" db:"since"`
+	HighWaterMark *time.Time   `json:"high_water_mark,omitempty" db:"high_water_mark"`
+	StartedAt     time.Time    `json:"started_at" db:"started_at"`
+	FinishedAt    *time.Time   `json:"finished_at,omitempty" db:"finished_at"`
+	Fetched       int          `json:"fetched" db:"fetched"`
+	Rejected      int          `json:"rejected" db:"rejected"`
+	Error         string       `json:"error,omitempty" db:"error"`
+	RequestID     *string      `json:"request_id,omitempty" db:"request_id"`
+	UpsertResult
+}
+
+// Reject содержит запись из SAP, не прошедшую валидацию
+type Reject struct {
+	ID           int64     `json:"-" db:"id"`
+	ImportID     int64     `json:"import_id" db:"import_id"`
+	AddressSapID string    `json:"address_sap_id" db:"address_sap_id"`
+	AdrSegment   string    `json:"adr_segment" db:"adr_segment"`
+	SegmentID    int64     `json:"segment_id" db:"segment_id"`
+	Reason       string    `json:"reason" db:"reason"`
+	Details      string    `json:"details" db:"details"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
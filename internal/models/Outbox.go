@@ -0,0 +1,22 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboxEventSegmentChanged - тип события об изменении записи сегментации
+const OutboxEventSegmentChanged = "segment.changed"
+
+// OutboxMessage - событие, ожидающее публикации в брокер
+type OutboxMessage struct {
+	ID           int64           `json:"id" db:"id"`
+	AddressSapID string          `json:"address_sap_id" db:"address_sap_id"`
+	EventType    string          `json:"event_type" db:"event_type"`
+	Payload      json.RawMessage `json:"payload" db:"payload"`
+	CreatedAt    time.Time       `json:"created_at" db:"created_at"`
+	PublishedAt  *time.Time      `json:"published_at,omitempty" db:"published_at"`
+	Attempts     int             `json:"attempts" db:"attempts"`
+	LastError    string          `json:"last_error,omitempty" db:"last_error"`
+	ClaimedUntil *time.Time      `json:"claimed_until,omitempty" db:"claimed_until"`
+}
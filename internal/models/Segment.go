@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Segment - запись справочника сегментов. ID совпадает с segment_id из SAP,
+// Code - с adr_segment.
+type Segment struct {
+	ID          int64     `json:"id" db:"id"`
+	Code        string    `json:"code" db:"code"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	Active      bool      `json:"active" db:"active"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
@@ -1,8 +1,52 @@
 package models
 
+import "time"
+
 type Segmentation struct {
-	ID           int64  `json:"-" db:"id"`
-	AddressSapID string `json:"address_sap_id" db:"address_sap_id"`
-	AdrSegment   string `json:"adr_segment" db:"adr_segment"`
-	SegmentID    int64  `json:"segment_id" db:"segment_id"`
+	ID           int64     `json:"-" db:"id"`
+	AddressSapID string    `json:"address_sap_id" db:"address_sap_id"`
+	AdrSegment   string    `json:"adr_segment" db:"adr_segment"`
+	SegmentID    int64     `json:"segment_id" db:"segment_id"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+
+	// ChangedAt - время изменения записи в SAP, используется для инкрементального импорта
+	ChangedAt *time.Time `json:"changed_at,omitempty" db:"-"`
+}
+
+// SegmentationVersion - версия набора данных сегментации: меняется при
+// вставке, изменении и удалении записей
+type SegmentationVersion struct {
+	Rows         int64     `json:"rows" db:"rows"`
+	LastModified time.Time `json:"last_modified" db:"last_modified"`
+}
+
+// Equal сообщает, совпадают ли версии
+func (v SegmentationVersion) Equal(other SegmentationVersion) bool {
+	return v.Rows == other.Rows && v.LastModified.Equal(other.LastModified)
+}
+
+// UpsertResult содержит количество строк, затронутых импортом
+type UpsertResult struct {
+	Inserted  int `json:"inserted" db:"inserted"`
+	Updated   int `json:"updated" db:"updated"`
+	Unchanged int `json:"unchanged" db:"unchanged"`
+}
+
+// Add суммирует результаты отдельных чанков
+func (r *UpsertResult) Add(other UpsertResult) {
+	r.Inserted += other.Inserted
+	r.Updated += other.Updated
+	r.Unchanged += other.Unchanged
+}
+
+// SegmentChange - запись, вставленная или измененная импортом. Для
+// измененной записи Old* содержат прежние значения.
+type SegmentChange struct {
+	AddressSapID  string  `json:"address_sap_id" db:"address_sap_id"`
+	AdrSegment    string  `json:"adr_segment" db:"adr_segment"`
+	SegmentID     int64   `json:"segment_id" db:"segment_id"`
+	OldAdrSegment *string `json:"old_adr_segment,omitempty" db:"old_adr_segment"`
+	OldSegmentID  *int64  `json:"old_segment_id,omitempty" db:"old_segment_id"`
+	Inserted      bool    `json:"inserted" db:"inserted"`
 }
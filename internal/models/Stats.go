@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// SegmentCount - количество адресов с парой adr_segment/segment_id
+type SegmentCount struct {
+	// Prefix - префикс address_sap_id (SAP-, SEG-, ADR-) при группировке по префиксу
+	Prefix     string `json:"prefix,omitempty" db:"prefix"`
+	AdrSegment string `json:"adr_segment" db:"adr_segment"`
+	SegmentID  int64  `json:"segment_id" db:"segment_id"`
+	// Name - название сегмента из справочника
+	Name  string `json:"name,omitempty" db:"name"`
+	Count int64  `json:"count" db:"count"`
+	// Delta - изменение относительно предыдущего импорта
+	Delta *int64 `json:"delta,omitempty" db:"-"`
+}
+
+// SegmentStats - распределение адресов по сегментам
+type SegmentStats struct {
+	Total int64 `json:"total"`
+	// Prefixes - итоги по префиксам при группировке по префиксу
+	Prefixes map[string]int64 `json:"prefixes,omitempty"`
+	Segments []SegmentCount   `json:"segments"`
+	// Imports - распределение после последних импортов, новые первыми
+	Imports []ImportSegmentStats `json:"imports"`
+}
+
+// ImportSegmentStats - распределение по сегментам после импорта и его
+// изменение относительно предыдущего импорта
+type ImportSegmentStats struct {
+	ImportID   int64      `json:"import_id"`
+	Mode       ImportMode `json:"mode"`
+	FinishedAt *time.Time `json:"finished_at"`
+	Total      int64      `json:"total"`
+	// TotalDelta отсутствует, если предыдущего импорта в истории нет
+	TotalDelta *int64           `json:"total_delta,omitempty"`
+	Prefixes   map[string]int64 `json:"prefixes,omitempty"`
+	Segments   []SegmentCount   `json:"segments"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// WebhookDelivery - попытка доставки события получателю
+type WebhookDelivery struct {
+	ID         int64     `json:"id" db:"id"`
+	EventID    string    `json:"event_id" db:"event_id"`
+	EventType  string    `json:"event_type" db:"event_type"`
+	URL        string    `json:"url" db:"url"`
+	Attempt    int       `json:"attempt" db:"attempt"`
+	StatusCode *int      `json:"status_code,omitempty" db:"status_code"`
+	Error      string    `json:"error,omitempty" db:"error"`
+	DurationMs int64     `json:"duration_ms" db:"duration_ms"`
+	Succeeded  bool      `json:"succeeded" db:"succeeded"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,100 @@
+// Package outbox публикует изменения сегментации, записанные в таблицу
+// segmentation_outbox в транзакции импорта, в брокер сообщений. Доставка -
+// не менее одного раза (получатель убирает повторы по Message.ID), порядок
+// сохраняется в пределах address_sap_id.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"go-test/internal/models"
+	"go-test/pkg/config"
+)
+
+// Брокеры
+const (
+	BrokerNATS   = "nats"
+	BrokerKafka  = "kafka"
+	BrokerFile   = "file"
+	BrokerMemory = "memory"
+)
+
+// Message - сообщение для брокера
+type Message struct {
+	// ID - идентификатор события в outbox, одинаковый при повторной публикации
+	ID string
+	// Key - ключ упорядочивания (address_sap_id)
+	Key   string
+	Topic string
+	// Body - JSON с событием (см. Envelope)
+	Body []byte
+}
+
+// Envelope - тело сообщения
+type Envelope struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Key       string          `json:"key"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Broker публикует сообщения. Publish возвращает nil только после того,
+// как брокер принял сообщение. Вызовы Publish не выполняются одновременно.
+type Broker interface {
+	Publish(ctx context.Context, msg Message) error
+	Close() error
+}
+
+// NewBroker создает брокер, выбранный в OUTBOX_BROKER
+func NewBroker(cfg *config.Config, logger *slog.Logger) (Broker, error) {
+	ob := cfg.Outbox
+
+	switch ob.Broker {
+	case BrokerNATS:
+		return NewNATSBroker(NATSOptions{
+			URL:      ob.NATSURL,
+			User:     ob.NATSUser,
+			Password: ob.NATSPassword.Value(),
+			Token:    ob.NATSToken.Value(),
+			Timeout:  ob.Timeout,
+			Logger:   logger,
+		})
+	case BrokerKafka:
+		return NewKafkaBroker(KafkaOptions{
+			URL:      ob.KafkaURL,
+			User:     ob.KafkaUser,
+			Password: ob.KafkaPassword.Value(),
+			Timeout:  ob.Timeout,
+		}), nil
+	case BrokerFile:
+		return NewFileBroker(ob.File)
+	case BrokerMemory:
+		return NewMemoryBroker(), nil
+	default:
+		return nil, fmt.Errorf("unknown outbox broker: %q", ob.Broker)
+	}
+}
+
+// newMessage собирает сообщение из события outbox
+func newMessage(topic string, m *models.OutboxMessage) (Message, error) {
+	id := strconv.FormatInt(m.ID, 10)
+
+	body, err := json.Marshal(Envelope{
+		ID:        id,
+		Type:      m.EventType,
+		Key:       m.AddressSapID,
+		CreatedAt: m.CreatedAt,
+		Data:      m.Payload,
+	})
+	if err != nil {
+		return Message{}, fmt.Errorf("failed to encode outbox message %s: %w", id, err)
+	}
+
+	return Message{ID: id, Key: m.AddressSapID, Topic: topic, Body: body}, nil
+}
@@ -0,0 +1,55 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileBroker дописывает тела сообщений в файл, по одному JSON на строку
+// (JSON Lines). Publish возвращается после fsync.
+type FileBroker struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileBroker(path string) (*FileBroker, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create outbox directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open outbox file: %w", err)
+	}
+
+	return &FileBroker{file: file}, nil
+}
+
+func (b *FileBroker) Publish(_ context.Context, msg Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	w := bufio.NewWriter(b.file)
+	w.Write(msg.Body)
+	w.WriteByte('\n')
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write outbox file: %w", err)
+	}
+
+	if err := b.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync outbox file: %w", err)
+	}
+
+	return nil
+}
+
+func (b *FileBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.file.Close()
+}
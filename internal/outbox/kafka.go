@@ -0,0 +1,115 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxKafkaErrorBodySize ограничивает тело ошибки, попадающее в сообщение
+const maxKafkaErrorBodySize = 4096
+
+// KafkaOptions - подключение к Kafka REST Proxy
+type KafkaOptions struct {
+	URL      string
+	User     string
+	Password string
+	Timeout  time.Duration
+}
+
+// KafkaBroker публикует сообщения через Kafka REST Proxy (API v2) в
+// формате json. Сообщения отправляются с ключом address_sap_id, поэтому
+// события одной записи попадают в одну партицию и читаются по порядку.
+type KafkaBroker struct {
+	opts       KafkaOptions
+	httpClient *http.Client
+}
+
+type kafkaRecord struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+type kafkaProduceRequest struct {
+	Records []kafkaRecord `json:"records"`
+}
+
+type kafkaProduceResponse struct {
+	Offsets []struct {
+		Partition *int    `json:"partition"`
+		Offset    *int64  `json:"offset"`
+		ErrorCode *int    `json:"error_code"`
+		Error     *string `json:"error"`
+	} `json:"offsets"`
+}
+
+func NewKafkaBroker(opts KafkaOptions) *KafkaBroker {
+	return &KafkaBroker{
+		opts:       opts,
+		httpClient: &http.Client{Timeout: opts.Timeout},
+	}
+}
+
+func (b *KafkaBroker) Publish(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(kafkaProduceRequest{
+		Records: []kafkaRecord{{Key: msg.Key, Value: msg.Body}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode Kafka request: %w", err)
+	}
+
+	endpoint := strings.TrimRight(b.opts.URL, "/") + "/topics/" + url.PathEscape(msg.Topic)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	if b.opts.User != "" {
+		req.SetBasicAuth(b.opts.User, b.opts.Password)
+	}
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to publish to Kafka: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxKafkaErrorBodySize))
+		return fmt.Errorf("error response from Kafka REST Proxy: status=%d, body=%s",
+			resp.StatusCode, string(respBody))
+	}
+
+	var produced kafkaProduceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&produced); err != nil {
+		return fmt.Errorf("failed to decode Kafka response: %w", err)
+	}
+	if len(produced.Offsets) != 1 {
+		return fmt.Errorf("unexpected Kafka response: %d offsets", len(produced.Offsets))
+	}
+	if offset := produced.Offsets[0]; offset.ErrorCode != nil || offset.Error != nil {
+		var code int
+		var message string
+		if offset.ErrorCode != nil {
+			code = *offset.ErrorCode
+		}
+		if offset.Error != nil {
+			message = *offset.Error
+		}
+		return fmt.Errorf("Kafka rejected message: code=%d, error=%s", code, message)
+	}
+
+	return nil
+}
+
+func (b *KafkaBroker) Close() error {
+	b.httpClient.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,53 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryBroker хранит сообщения в памяти; для тестов
+type MemoryBroker struct {
+	mu       sync.Mutex
+	messages []Message
+	// err возвращается из Publish, пока задан
+	err error
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{}
+}
+
+func (b *MemoryBroker) Publish(_ context.Context, msg Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.err != nil {
+		return b.err
+	}
+	b.messages = append(b.messages, msg)
+
+	return nil
+}
+
+// Messages возвращает копию опубликованных сообщений
+func (b *MemoryBroker) Messages() []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	messages := make([]Message, len(b.messages))
+	copy(messages, b.messages)
+
+	return messages
+}
+
+// SetError включает (err != nil) или выключает отказ публикации
+func (b *MemoryBroker) SetError(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.err = err
+}
+
+func (b *MemoryBroker) Close() error {
+	return nil
+}
@@ -0,0 +1,381 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// natsDefaultPort - порт NATS, если он не указан в адресе
+const natsDefaultPort = "4222"
+
+// NATSOptions - подключение к NATS
+type NATSOptions struct {
+	// URL вида nats://[user:password@]host[:port]
+	URL      string
+	User     string
+	Password string
+	Token    string
+	Timeout  time.Duration
+	Logger   *slog.Logger
+}
+
+// NATSBroker публикует сообщения в поток JetStream по текстовому протоколу
+// клиента NATS без внешних зависимостей. Сообщение отправляется с адресом
+// ответа во входящем ящике соединения, и публикация считается успешной
+// только после подтверждения (PubAck) от JetStream: ответ PONG на обычную
+// публикацию означает лишь, что сервер прочитал сообщение, но не сохранил
+// его. Если сервер поддерживает заголовки, сообщение получает Nats-Msg-Id
+// для отсечения повторов. При ошибке соединение закрывается и открывается
+// заново при следующей публикации.
+type NATSBroker struct {
+	opts NATSOptions
+	addr string
+
+	conn    net.Conn
+	r       *bufio.Reader
+	headers bool
+	// inbox - префикс адресов ответа, на который подписано соединение
+	inbox string
+	seq   uint64
+}
+
+// natsInboxSID - идентификатор подписки на входящий ящик
+const natsInboxSID = "1"
+
+// natsPubAck - ответ JetStream на публикацию
+type natsPubAck struct {
+	Stream    string `json:"stream"`
+	Seq       uint64 `json:"seq"`
+	Duplicate bool   `json:"duplicate"`
+	Error     *struct {
+		Code        int    `json:"code"`
+		ErrCode     int    `json:"err_code"`
+		Description string `json:"description"`
+	} `json:"error"`
+}
+
+// natsInfo - интересующая нас часть сообщения INFO сервера
+type natsInfo struct {
+	Headers     bool `json:"headers"`
+	TLSRequired bool `json:"tls_required"`
+}
+
+// natsConnect - параметры команды CONNECT
+type natsConnect struct {
+	Verbose  bool   `json:"verbose"`
+	Pedantic bool   `json:"pedantic"`
+	Name     string `json:"name"`
+	Lang     string `json:"lang"`
+	Version  string `json:"version"`
+	Protocol int    `json:"protocol"`
+	Headers  bool   `json:"headers"`
+	// NoResponders - сервер сразу отвечает статусом 503, если на subject
+	// никто не подписан (нет потока JetStream)
+	NoResponders bool   `json:"no_responders"`
+	User         string `json:"user,omitempty"`
+	Pass         string `json:"pass,omitempty"`
+	AuthToken    string `json:"auth_token,omitempty"`
+}
+
+func NewNATSBroker(opts NATSOptions) (*NATSBroker, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil || u.Scheme != "nats" || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid NATS URL: %q", opts.URL)
+	}
+
+	if u.User != nil && opts.User == "" {
+		opts.User = u.User.Username()
+		opts.Password, _ = u.User.Password()
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = natsDefaultPort
+	}
+
+	return &NATSBroker{opts: opts, addr: net.JoinHostPort(u.Hostname(), port)}, nil
+}
+
+func (b *NATSBroker) Publish(ctx context.Context, msg Message) error {
+	if b.conn == nil {
+		if err := b.connect(ctx); err != nil {
+			return err
+		}
+	}
+
+	if err := b.publish(ctx, msg); err != nil {
+		b.Close()
+		return err
+	}
+
+	return nil
+}
+
+func (b *NATSBroker) Close() error {
+	if b.conn == nil {
+		return nil
+	}
+
+	err := b.conn.Close()
+	b.conn = nil
+	b.r = nil
+
+	return err
+}
+
+func (b *NATSBroker) connect(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: b.opts.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", b.addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+
+	b.conn = conn
+	b.r = bufio.NewReader(conn)
+	b.setDeadline(ctx)
+
+	line, err := b.readLine()
+	if err != nil {
+		b.Close()
+		return fmt.Errorf("failed to read NATS INFO: %w", err)
+	}
+	payload, ok := strings.CutPrefix(line, "INFO ")
+	if !ok {
+		b.Close()
+		return fmt.Errorf("unexpected NATS greeting: %q", line)
+	}
+
+	var info natsInfo
+	if err := json.Unmarshal([]byte(payload), &info); err != nil {
+		b.Close()
+		return fmt.Errorf("failed to decode NATS INFO: %w", err)
+	}
+	if info.TLSRequired {
+		b.Close()
+		return errors.New("NATS server requires TLS, which is not supported")
+	}
+	b.headers = info.Headers
+
+	connect, err := json.Marshal(natsConnect{
+		Name:         "sap_segmentationd",
+		Lang:         "go",
+		Version:      "1.0",
+		Protocol:     1,
+		Headers:      info.Headers,
+		NoResponders: info.Headers,
+		User:         b.opts.User,
+		Pass:         b.opts.Password,
+		AuthToken:    b.opts.Token,
+	})
+	if err != nil {
+		b.Close()
+		return fmt.Errorf("failed to encode NATS CONNECT: %w", err)
+	}
+
+	inbox, err := newNATSInbox()
+	if err != nil {
+		b.Close()
+		return err
+	}
+	b.inbox = inbox
+
+	if _, err := fmt.Fprintf(b.conn, "CONNECT %s\r\nSUB %s.* %s\r\nPING\r\n", connect, b.inbox, natsInboxSID); err != nil {
+		b.Close()
+		return fmt.Errorf("failed to send NATS CONNECT: %w", err)
+	}
+	if err := b.waitPong(); err != nil {
+		b.Close()
+		return fmt.Errorf("NATS rejected connection: %w", err)
+	}
+
+	if b.opts.Logger != nil {
+		b.opts.Logger.Info("connected to NATS", "address", b.addr, "headers", info.Headers)
+	}
+
+	return nil
+}
+
+func (b *NATSBroker) publish(ctx context.Context, msg Message) error {
+	b.setDeadline(ctx)
+
+	b.seq++
+	reply := b.inbox + "." + strconv.FormatUint(b.seq, 10)
+
+	w := bufio.NewWriter(b.conn)
+	if b.headers {
+		hdr := "NATS/1.0\r\nNats-Msg-Id: " + msg.ID + "\r\n\r\n"
+		fmt.Fprintf(w, "HPUB %s %s %d %d\r\n%s", msg.Topic, reply, len(hdr), len(hdr)+len(msg.Body), hdr)
+	} else {
+		fmt.Fprintf(w, "PUB %s %s %d\r\n", msg.Topic, reply, len(msg.Body))
+	}
+	w.Write(msg.Body)
+	w.WriteString("\r\n")
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to publish to NATS: %w", err)
+	}
+
+	payload, err := b.waitReply(reply)
+	if err != nil {
+		return fmt.Errorf("failed to publish to NATS: %w", err)
+	}
+
+	var ack natsPubAck
+	if err := json.Unmarshal(payload, &ack); err != nil {
+		return fmt.Errorf("failed to decode JetStream ack: %w", err)
+	}
+	if ack.Error != nil {
+		return fmt.Errorf("JetStream rejected message: code=%d, err_code=%d, description=%s",
+			ack.Error.Code, ack.Error.ErrCode, ack.Error.Description)
+	}
+	if ack.Stream == "" {
+		return fmt.Errorf("unexpected JetStream ack: %s", payload)
+	}
+
+	return nil
+}
+
+// waitReply читает ответы сервера до сообщения на адрес reply и
+// возвращает его тело. Ответы на прежние адреса пропускаются.
+func (b *NATSBroker) waitReply(reply string) ([]byte, error) {
+	for {
+		line, err := b.readLine()
+		if err != nil {
+			return nil, err
+		}
+
+		op, args, _ := strings.Cut(line, " ")
+		switch op {
+		case "MSG", "HMSG":
+			subject, hdr, payload, err := b.readMsg(op, strings.Fields(args))
+			if err != nil {
+				return nil, err
+			}
+			if subject != reply {
+				continue
+			}
+			if status := natsStatus(hdr); status == "503" {
+				return nil, errors.New("no JetStream stream for subject")
+			} else if status != "" {
+				return nil, fmt.Errorf("unexpected status %s", status)
+			}
+			return payload, nil
+		case "PING":
+			if _, err := b.conn.Write([]byte("PONG\r\n")); err != nil {
+				return nil, err
+			}
+		case "-ERR":
+			return nil, fmt.Errorf("server error: %s", strings.TrimSpace(args))
+		}
+		// +OK, PONG и INFO не требуют ответа
+	}
+}
+
+// readMsg читает тело MSG (subject sid [reply] size) или
+// HMSG (subject sid [reply] hdr_size total_size)
+func (b *NATSBroker) readMsg(op string, args []string) (subject string, hdr, payload []byte, err error) {
+	sizes := 1
+	if op == "HMSG" {
+		sizes = 2
+	}
+	if len(args) < 2+sizes {
+		return "", nil, nil, fmt.Errorf("malformed %s: %q", op, args)
+	}
+
+	total, err := strconv.Atoi(args[len(args)-1])
+	if err != nil || total < 0 {
+		return "", nil, nil, fmt.Errorf("malformed %s size: %q", op, args)
+	}
+	hdrSize := 0
+	if op == "HMSG" {
+		hdrSize, err = strconv.Atoi(args[len(args)-2])
+		if err != nil || hdrSize < 0 || hdrSize > total {
+			return "", nil, nil, fmt.Errorf("malformed %s header size: %q", op, args)
+		}
+	}
+
+	buf := make([]byte, total+2)
+	if _, err := io.ReadFull(b.r, buf); err != nil {
+		return "", nil, nil, err
+	}
+
+	return args[0], buf[:hdrSize], buf[hdrSize:total], nil
+}
+
+// natsStatus возвращает код статуса из заголовков сообщения ("NATS/1.0 503")
+func natsStatus(hdr []byte) string {
+	line, _, _ := strings.Cut(string(hdr), "\r\n")
+	_, rest, ok := strings.Cut(line, " ")
+	if !ok {
+		return ""
+	}
+	status, _, _ := strings.Cut(strings.TrimSpace(rest), " ")
+
+	return status
+}
+
+// newNATSInbox возвращает уникальный префикс адресов ответа
+func newNATSInbox() (string, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate NATS inbox: %w", err)
+	}
+
+	return "_INBOX." + hex.EncodeToString(buf), nil
+}
+
+// waitPong читает ответы сервера до PONG, отвечая на его PING
+func (b *NATSBroker) waitPong() error {
+	for {
+		line, err := b.readLine()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := b.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("server error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+		// +OK и INFO не требуют ответа
+	}
+}
+
+func (b *NATSBroker) readLine() (string, error) {
+	line, err := b.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// setDeadline ограничивает операцию с соединением таймаутом и сроком ctx
+func (b *NATSBroker) setDeadline(ctx context.Context) {
+	deadline := time.Now().Add(b.opts.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	_ = b.conn.SetDeadline(deadline)
+}
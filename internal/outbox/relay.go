@@ -0,0 +1,112 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go-test/internal/metrics"
+	"go-test/internal/models"
+	"go-test/internal/repository"
+	"go-test/pkg/config"
+)
+
+// cleanupInterval - периодичность удаления старых опубликованных событий
+const cleanupInterval = time.Hour
+
+var (
+	publishedTotal = metrics.NewCounter("outbox_published_total",
+		"Количество событий outbox, опубликованных в брокер")
+	publishErrorsTotal = metrics.NewCounter("outbox_publish_errors_total",
+		"Количество неудачных попыток публикации событий outbox")
+)
+
+// Relay переносит события из segmentation_outbox в брокер
+type Relay struct {
+	logger *slog.Logger
+	repo   *repository.OutboxRepository
+	broker Broker
+
+	topic        string
+	pollInterval time.Duration
+	batchSize    int
+	retention    time.Duration
+	lease        time.Duration
+}
+
+func NewRelay(cfg *config.Config, logger *slog.Logger, repo *repository.OutboxRepository, broker Broker) *Relay {
+	return &Relay{
+		logger:       logger,
+		repo:         repo,
+		broker:       broker,
+		topic:        cfg.Outbox.Topic,
+		pollInterval: cfg.Outbox.PollInterval,
+		batchSize:    cfg.Outbox.BatchSize,
+		retention:    cfg.Outbox.Retention,
+		lease:        cfg.Outbox.Lease,
+	}
+}
+
+// Run публикует события до отмены ctx. Пока пачки заполнены целиком,
+// следующая выбирается сразу, иначе - через OUTBOX_POLL_INTERVAL.
+// Опубликованные события старше OUTBOX_RETENTION удаляются раз в час.
+func (r *Relay) Run(ctx context.Context) {
+	r.logger.Info("starting outbox relay", "topic", r.topic, "poll_interval", r.pollInterval)
+
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	var lastCleanup time.Time
+
+	for {
+		batch, err := r.repo.Publish(ctx, r.batchSize, r.lease, r.publish)
+		if err != nil && ctx.Err() == nil {
+			r.logger.Error("failed to process outbox", "error", err.Error())
+		}
+
+		if r.retention > 0 && time.Since(lastCleanup) >= cleanupInterval {
+			lastCleanup = time.Now()
+			if removed, err := r.repo.DeletePublished(ctx, time.Now().Add(-r.retention)); err != nil {
+				r.logger.Error("failed to cleanup outbox", "error", err.Error())
+			} else if removed > 0 {
+				r.logger.Info("outbox cleanup completed", "removed", removed)
+			}
+		}
+
+		// Полная пачка без ошибок - вероятно, есть еще события
+		if err == nil && batch.Failed == 0 && batch.Published == r.batchSize {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Relay) publish(ctx context.Context, m *models.OutboxMessage) error {
+	msg, err := newMessage(r.topic, m)
+	if err != nil {
+		return err
+	}
+
+	if err := r.broker.Publish(ctx, msg); err != nil {
+		publishErrorsTotal.Inc()
+		r.logger.Warn("failed to publish outbox message",
+			"id", m.ID,
+			"address_sap_id", m.AddressSapID,
+			"attempts", m.Attempts+1,
+			"error", err.Error(),
+		)
+		return err
+	}
+
+	publishedTotal.Inc()
+
+	return nil
+}
@@ -0,0 +1,246 @@
+// Package progress собирает события хода импорта для трансляции клиентам
+// (см. GET /api/imports/{id}/events). Трекер импорта передается через
+// context, поэтому клиент SAP и репозитории сообщают о ходе работы, не
+// зная об импорте; без трекера в контексте вызовы ничего не делают.
+package progress
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go-test/internal/models"
+)
+
+// Типы событий
+const (
+	EventStarted       = "started"
+	EventPageFetched   = "page_fetched"
+	EventRetry         = "retry"
+	EventValidated     = "validated"
+	EventBatchUpserted = "batch_upserted"
+	EventCompleted     = "completed"
+)
+
+// retention - сколько хранить события завершенного импорта
+const retention = 10 * time.Minute
+
+// maxEvents ограничивает число хранимых событий одного импорта; сверх него
+// промежуточные события не сохраняются, completed сохраняется всегда
+const maxEvents = 10000
+
+// Counts - накопленные показатели импорта
+type Counts struct {
+	Pages     int `json:"pages"`
+	Fetched   int `json:"fetched"`
+	Retries   int `json:"retries"`
+	Rejected  int `json:"rejected"`
+	Inserted  int `json:"inserted"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+}
+
+// Event - событие хода импорта с показателями на его момент
+type Event struct {
+	Type     string         `json:"type"`
+	ImportID int64          `json:"import_id"`
+	Time     time.Time      `json:"time"`
+	Counts   Counts         `json:"counts"`
+	Data     map[string]any `json:"data,omitempty"`
+}
+
+// Hub хранит трекеры текущих и недавно завершенных импортов
+type Hub struct {
+	mu       sync.Mutex
+	trackers map[int64]*Tracker
+}
+
+func NewHub() *Hub {
+	return &Hub{trackers: make(map[int64]*Tracker)}
+}
+
+// Track создает трекер импорта
+func (h *Hub) Track(importID int64) *Tracker {
+	t := &Tracker{hub: h, importID: importID, changed: make(chan struct{})}
+
+	h.mu.Lock()
+	h.trackers[importID] = t
+	h.mu.Unlock()
+
+	return t
+}
+
+// Get возвращает трекер импорта, если он еще хранится
+func (h *Hub) Get(importID int64) (*Tracker, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	t, ok := h.trackers[importID]
+	return t, ok
+}
+
+func (h *Hub) remove(t *Tracker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.trackers[t.importID] == t {
+		delete(h.trackers, t.importID)
+	}
+}
+
+// Tracker накапливает события одного импорта. Методы безопасны для
+// одновременного вызова и для nil-трекера.
+type Tracker struct {
+	hub      *Hub
+	importID int64
+
+	mu     sync.Mutex
+	counts Counts
+	events []Event
+	done   bool
+	// changed закрывается и заменяется при каждом новом событии
+	changed chan struct{}
+}
+
+// Started сообщает о начале импорта
+func (t *Tracker) Started(mode models.ImportMode, since *time.Time) {
+	t.emit(EventStarted, nil, map[string]any{"mode": mode, "since": since})
+}
+
+// PageFetched сообщает о загруженной странице SAP API
+func (t *Tracker) PageFetched(records int) {
+	t.emit(EventPageFetched, func(c *Counts) {
+		c.Pages++
+		c.Fetched += records
+	}, map[string]any{"records": records})
+}
+
+// Retry сообщает о повторе запроса к SAP API
+func (t *Tracker) Retry(status, attempt int, wait time.Duration) {
+	data := map[string]any{"status": status, "attempt": attempt}
+	if wait > 0 {
+		data["retry_after"] = wait.String()
+	}
+	t.emit(EventRetry, func(c *Counts) { c.Retries++ }, data)
+}
+
+// Validated сообщает о результате валидации
+func (t *Tracker) Validated(valid, rejected int) {
+	t.emit(EventValidated, func(c *Counts) { c.Rejected = rejected }, map[string]any{"valid": valid})
+}
+
+// BatchUpserted сообщает о сохраненном чанке
+func (t *Tracker) BatchUpserted(offset, size int, result models.UpsertResult) {
+	t.emit(EventBatchUpserted, func(c *Counts) {
+		c.Inserted += result.Inserted
+		c.Updated += result.Updated
+		c.Unchanged += result.Unchanged
+	}, map[string]any{"offset": offset, "size": size})
+}
+
+// Completed завершает трекер итоговым событием. Через некоторое время
+// трекер удаляется из Hub.
+func (t *Tracker) Completed(imp *models.Import) {
+	if t == nil {
+		return
+	}
+
+	completed := CompletedEvent(imp)
+	// Итог импорта точнее промежуточных сумм: дубликаты, откат транзакции
+	t.emit(EventCompleted, func(c *Counts) {
+		pages, retries := c.Pages, c.Retries
+		*c = completed.Counts
+		c.Pages, c.Retries = pages, retries
+	}, completed.Data)
+
+	time.AfterFunc(retention, func() { t.hub.remove(t) })
+}
+
+// CompletedEvent строит итоговое событие по записи об импорте; используется,
+// когда трекера уже нет (импорт завершился давно или на другом экземпляре)
+func CompletedEvent(imp *models.Import) Event {
+	data := map[string]any{"status": imp.Status}
+	if imp.Error != "" {
+		data["error"] = imp.Error
+	}
+
+	at := imp.StartedAt
+	if imp.FinishedAt != nil {
+		at = *imp.FinishedAt
+	}
+
+	return Event{
+		Type:     EventCompleted,
+		ImportID: imp.ID,
+		Time:     at,
+		Counts: Counts{
+			Fetched:   imp.Fetched,
+			Rejected:  imp.Rejected,
+			Inserted:  imp.Inserted,
+			Updated:   imp.Updated,
+			Unchanged: imp.Unchanged,
+		},
+		Data: data,
+	}
+}
+
+// Since возвращает события, начиная с номера from, признак завершения
+// импорта и канал, который закроется при появлении нового события
+func (t *Tracker) Since(from int) ([]Event, bool, <-chan struct{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var events []Event
+	if from < len(t.events) {
+		events = append(events, t.events[max(from, 0):]...)
+	}
+
+	return events, t.done, t.changed
+}
+
+func (t *Tracker) emit(eventType string, update func(*Counts), data map[string]any) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.done {
+		return
+	}
+
+	if update != nil {
+		update(&t.counts)
+	}
+
+	if len(t.events) < maxEvents || eventType == EventCompleted {
+		t.events = append(t.events, Event{
+			Type:     eventType,
+			ImportID: t.importID,
+			Time:     time.Now(),
+			Counts:   t.counts,
+			Data:     data,
+		})
+	}
+
+	if eventType == EventCompleted {
+		t.done = true
+	}
+
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+type trackerKey struct{}
+
+// WithTracker сохраняет трекер импорта в контексте
+func WithTracker(ctx context.Context, t *Tracker) context.Context {
+	return context.WithValue(ctx, trackerKey{}, t)
+}
+
+// FromContext возвращает трекер из контекста или nil
+func FromContext(ctx context.Context) *Tracker {
+	t, _ := ctx.Value(trackerKey{}).(*Tracker)
+	return t
+}
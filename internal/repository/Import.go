@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"go-test/internal/models"
+)
+
+// importLockKey - ключ advisory-блокировки, под которой выполняется импорт
+const importLockKey = "hashtext('segmentation_import')"
+
+type ImportRepository struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+}
+
+func NewImportRepository(db *sqlx.DB, logger *slog.Logger) *ImportRepository {
+	return &ImportRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create регистрирует новый запуск импорта в статусе running. Пустой
+// requestID сохраняется как NULL.
+func (r *ImportRepository) Create(ctx context.Context, mode models.ImportMode, since *time.Time, requestID string) (*models.Import, error) {
+	var imp models.Import
+	err := r.db.GetContext(ctx, &imp, `
+		INSERT INTO imports (status, mode, since, request_id)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		RETURNING *
+	`, models.ImportStatusRunning, mode, since, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create import: %w", err)
+	}
+
+	return &imp, nil
+}
+
+// Finish сохраняет итоговый статус и счетчики импорта
+func (r *ImportRepository) Finish(ctx context.Context, imp *models.Import) error {
+	err := r.db.GetContext(ctx, &imp.FinishedAt, `
+		UPDATE imports
+		SET status = $2,
+			finished_at = now(),
+			fetched = $3,
+			rejected = $4,
+			inserted = $5,
+			updated = $6,
+			unchanged = $7,
+			error = $8,
+			high_water_mark = $9
+		WHERE id = $1
+		RETURNING finished_at
+	`, imp.ID, imp.Status, imp.Fetched, imp.Rejected, imp.Inserted, imp.Updated, imp.Unchanged, imp.Error,
+		imp.HighWaterMark)
+	if err != nil {
+		return fmt.Errorf("failed to finish import %d: %w", imp.ID, err)
+	}
+
+	return nil
+}
+
+// Lock берет сессионную advisory-блокировку импорта на отдельном соединении,
+// чтобы импорт одновременно выполнял только один экземпляр сервиса.
+// locked = false, если блокировку держит другой импорт. release снимает
+// блокировку и возвращает соединение в пул.
+func (r *ImportRepository) Lock(ctx context.Context) (release func(), locked bool, err error) {
+	conn, err := r.db.Connx(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get connection for import lock: %w", err)
+	}
+
+	if err := conn.GetContext(ctx, &locked, "SELECT pg_try_advisory_lock("+importLockKey+")"); err != nil {
+		conn.Close()
+		return nil, false, fmt.Errorf("failed to lock import: %w", err)
+	}
+	if !locked {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	release = func() {
+		// Если unlock не удался, соединение закрывается вместе с сессией,
+		// а с ней снимается и блокировка
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock("+importLockKey+")"); err != nil {
+			r.logger.ErrorContext(ctx, "failed to unlock import", "error", err.Error())
+			_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}
+
+	return release, true, nil
+}
+
+// Running возвращает последний импорт в статусе running или nil
+func (r *ImportRepository) Running(ctx context.Context) (*models.Import, error) {
+	var imp models.Import
+	err := r.db.GetContext(ctx, &imp, `
+		SELECT * FROM imports WHERE status = $1 ORDER BY id DESC LIMIT 1
+	`, models.ImportStatusRunning)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get running import: %w", err)
+	}
+
+	return &imp, nil
+}
+
+func (r *ImportRepository) GetByID(id int64) (*models.Import, error) {
+	var imp models.Import
+	err := r.db.Get(&imp, "SELECT * FROM imports WHERE id = $1", id)
+	return &imp, err
+}
+
+// LastHighWaterMark возвращает максимальное время изменения в SAP среди
+// успешных импортов или nil, если оно еще не известно
+func (r *ImportRepository) LastHighWaterMark(ctx context.Context) (*time.Time, error) {
+	var mark *time.Time
+	err := r.db.GetContext(ctx, &mark, `
+		SELECT max(high_water_mark) FROM imports WHERE status = $1
+	`, models.ImportStatusSucceeded)
+	return mark, err
+}
+
+// LastFullImportAt возвращает время завершения последнего успешного полного
+// импорта или nil, если такого еще не было
+func (r *ImportRepository) LastFullImportAt(ctx context.Context) (*time.Time, error) {
+	var finishedAt *time.Time
+	err := r.db.GetContext(ctx, &finishedAt, `
+		SELECT finished_at FROM imports
+		WHERE status = $1 AND mode = $2
+		ORDER BY finished_at DESC
+		LIMIT 1
+	`, models.ImportStatusSucceeded, models.ImportModeFull)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return finishedAt, err
+}
+
+// SaveRejects переносит отклоненные записи в карантинную таблицу
+func (r *ImportRepository) SaveRejects(ctx context.Context, importID int64, rejects []models.Reject) (err error) {
+	if len(rejects) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("segmentation_rejects",
+		"import_id", "address_sap_id", "adr_segment", "segment_id", "reason", "details"))
+	if err != nil {
+		return fmt.Errorf("failed to prepare copy: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, reject := range rejects {
+		if _, err = stmt.ExecContext(ctx, importID, reject.AddressSapID, reject.AdrSegment, reject.SegmentID,
+			reject.Reason, reject.Details); err != nil {
+			return fmt.Errorf("failed to copy reject %q: %w", reject.AddressSapID, err)
+		}
+	}
+
+	if _, err = stmt.ExecContext(ctx); err != nil {
+		return fmt.Errorf("failed to flush copy: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.logger.DebugContext(ctx, "saved import rejects", "import_id", importID, "count", len(rejects))
+
+	return nil
+}
+
+func (r *ImportRepository) GetRejects(importID int64) ([]*models.Reject, error) {
+	rejects := make([]*models.Reject, 0)
+	err := r.db.Select(&rejects,
+		"SELECT * FROM segmentation_rejects WHERE import_id = $1 ORDER BY id", importID)
+	return rejects, err
+}
@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"go-test/internal/models"
+)
+
+// Пауза перед повтором неуспешной публикации: удваивается с каждой
+// попыткой до outboxMaxRetryBackoff
+const (
+	outboxRetryBackoff    = time.Second
+	outboxMaxRetryBackoff = 5 * time.Minute
+)
+
+// OutboxRepository читает и отмечает события segmentation_outbox
+type OutboxRepository struct {
+	db     *sqlx.DB
+	logger *slog.Logger
+}
+
+func NewOutboxRepository(db *sqlx.DB, logger *slog.Logger) *OutboxRepository {
+	return &OutboxRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// OutboxBatch - итог обработки пачки событий
+type OutboxBatch struct {
+	Published int
+	Failed    int
+	// Locked - публикацию выполняет другой экземпляр сервиса
+	Locked bool
+}
+
+// Publish забирает до limit неопубликованных событий, передает их publish
+// и отмечает результат. События забираются в короткой транзакции под
+// advisory-блокировкой: для каждого address_sap_id берется только самое
+// старое неопубликованное событие, и только если оно не забрано другим
+// экземпляром, поэтому события одной записи публикуются по порядку, а
+// ошибка по одной записи не задерживает остальные. Забранные события
+// недоступны другим экземплярам в течение lease; publish вызывается вне
+// транзакции с ctx, ограниченным lease. Результат сохраняется второй
+// короткой транзакцией. Событие может быть опубликовано повторно, если
+// результат не удалось сохранить.
+func (r *OutboxRepository) Publish(ctx context.Context, limit int, lease time.Duration, publish func(context.Context, *models.OutboxMessage) error) (batch OutboxBatch, err error) {
+	messages, locked, err := r.claim(ctx, limit, lease)
+	if err != nil {
+		return batch, err
+	}
+	if !locked {
+		batch.Locked = true
+		return batch, nil
+	}
+	if len(messages) == 0 {
+		return batch, nil
+	}
+
+	publishCtx, cancel := context.WithTimeout(ctx, lease)
+	defer cancel()
+
+	var published []int64
+	var failed []int64
+	var errs []string
+
+	for _, msg := range messages {
+		if publishCtx.Err() != nil {
+			break
+		}
+
+		if publishErr := publish(publishCtx, msg); publishErr != nil {
+			failed = append(failed, msg.ID)
+			errs = append(errs, publishErr.Error())
+			continue
+		}
+
+		published = append(published, msg.ID)
+	}
+
+	// Сохраняем результат и после отмены ctx: опубликованное не должно
+	// публиковаться повторно без необходимости
+	if err = r.complete(context.WithoutCancel(ctx), published, failed, errs); err != nil {
+		return batch, err
+	}
+
+	batch.Published = len(published)
+	batch.Failed = len(failed)
+
+	if batch.Published > 0 || batch.Failed > 0 {
+		r.logger.DebugContext(ctx, "processed outbox batch", "published", batch.Published, "failed", batch.Failed)
+	}
+
+	return batch, nil
+}
+
+// claim забирает события для публикации на lease. locked = false, если
+// события забирает другой экземпляр сервиса.
+func (r *OutboxRepository) claim(ctx context.Context, limit int, lease time.Duration) (messages []*models.OutboxMessage, locked bool, err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil || !locked {
+			_ = tx.Rollback()
+		}
+	}()
+
+	err = tx.GetContext(ctx, &locked, "SELECT pg_try_advisory_xact_lock(hashtext('segmentation_outbox'))")
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to lock outbox: %w", err)
+	}
+	if !locked {
+		return nil, false, nil
+	}
+
+	err = tx.SelectContext(ctx, &messages, `
+		UPDATE segmentation_outbox o
+		SET claimed_until = now() + make_interval(secs => $2)
+		FROM (
+			SELECT id FROM (
+				SELECT DISTINCT ON (address_sap_id) id, claimed_until
+				FROM segmentation_outbox
+				WHERE published_at IS NULL
+				ORDER BY address_sap_id, id
+			) head
+			WHERE claimed_until IS NULL OR claimed_until < now()
+			ORDER BY id
+			LIMIT $1
+		) c
+		WHERE o.id = c.id
+		RETURNING o.*
+	`, limit, lease.Seconds())
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to claim outbox messages: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, true, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// RETURNING не гарантирует порядок
+	slices.SortFunc(messages, func(a, b *models.OutboxMessage) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return messages, true, nil
+}
+
+// complete отмечает опубликованные события и увеличивает счетчик попыток
+// у неуспешных. Неуспешное событие (и следующие события его записи) не
+// забирается до истечения паузы, растущей с числом попыток. Остальные
+// забранные события освобождаются по истечении lease.
+func (r *OutboxRepository) complete(ctx context.Context, published, failed []int64, errs []string) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if len(published) > 0 {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE segmentation_outbox SET published_at = now(), claimed_until = NULL
+			WHERE id = ANY($1)
+		`, pq.Array(published))
+		if err != nil {
+			return fmt.Errorf("failed to mark outbox messages published: %w", err)
+		}
+	}
+
+	if len(failed) > 0 {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE segmentation_outbox o
+			SET attempts = attempts + 1,
+				last_error = f.error,
+				claimed_until = now() + least(
+					make_interval(secs => $3 * power(2, least(attempts, 20))),
+					make_interval(secs => $4)
+				)
+			FROM unnest($1::bigint[], $2::text[]) AS f(id, error)
+			WHERE o.id = f.id
+		`, pq.Array(failed), pq.Array(errs), outboxRetryBackoff.Seconds(), outboxMaxRetryBackoff.Seconds())
+		if err != nil {
+			return fmt.Errorf("failed to record outbox errors: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// DeletePublished удаляет события, опубликованные раньше before
+func (r *OutboxRepository) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		"DELETE FROM segmentation_outbox WHERE published_at < $1", before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete published outbox messages: %w", err)
+	}
+
+	return res.RowsAffected()
+}
+
+// Pending возвращает количество неопубликованных событий
+func (r *OutboxRepository) Pending(ctx context.Context) (int, error) {
+	var n int
+	err := r.db.GetContext(ctx, &n, "SELECT count(*) FROM segmentation_outbox WHERE published_at IS NULL")
+	return n, err
+}
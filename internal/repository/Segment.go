@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"go-test/internal/models"
+)
+
+// Коды ошибок Postgres
+const (
+	pqUniqueViolation     = "23505"
+	pqForeignKeyViolation = "23503"
+)
+
+var (
+	// ErrSegmentExists - сегмент с таким id или кодом уже есть
+	ErrSegmentExists = errors.New("segment already exists")
+	// ErrSegmentInUse - на сегмент ссылаются записи сегментации
+	ErrSegmentInUse = errors.New("segment is in use")
+)
+
+// SegmentRepository работает со справочником сегментов
+type SegmentRepository struct {
+	db *sqlx.DB
+}
+
+func NewSegmentRepository(db *sqlx.DB) *SegmentRepository {
+	return &SegmentRepository{db: db}
+}
+
+// GetAll возвращает сегменты по возрастанию id. activeOnly оставляет
+// только активные.
+func (r *SegmentRepository) GetAll(ctx context.Context, activeOnly bool) ([]*models.Segment, error) {
+	segments := make([]*models.Segment, 0)
+	err := r.db.SelectContext(ctx, &segments, `
+		SELECT * FROM segments
+		WHERE active OR NOT $1
+		ORDER BY id
+	`, activeOnly)
+	return segments, err
+}
+
+func (r *SegmentRepository) GetByID(ctx context.Context, id int64) (*models.Segment, error) {
+	var segment models.Segment
+	err := r.db.GetContext(ctx, &segment, "SELECT * FROM segments WHERE id = $1", id)
+	return &segment, err
+}
+
+// Create добавляет сегмент. Если id или код уже заняты, возвращается
+// ErrSegmentExists.
+func (r *SegmentRepository) Create(ctx context.Context, segment *models.Segment) error {
+	err := r.db.GetContext(ctx, segment, `
+		INSERT INTO segments (id, code, name, description, active)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING *
+	`, segment.ID, segment.Code, segment.Name, segment.Description, segment.Active)
+	if isPQError(err, pqUniqueViolation) {
+		return ErrSegmentExists
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create segment %d: %w", segment.ID, err)
+	}
+
+	return nil
+}
+
+// Update изменяет название, описание и признак активности сегмента.
+// id и код не меняются: по ним записи SAP связываются со справочником.
+// Если сегмента нет, возвращается sql.ErrNoRows.
+func (r *SegmentRepository) Update(ctx context.Context, segment *models.Segment) error {
+	err := r.db.GetContext(ctx, segment, `
+		UPDATE segments
+		SET name = $2,
+			description = $3,
+			active = $4,
+			updated_at = now()
+		WHERE id = $1
+		RETURNING *
+	`, segment.ID, segment.Name, segment.Description, segment.Active)
+	if errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update segment %d: %w", segment.ID, err)
+	}
+
+	return nil
+}
+
+// Delete удаляет сегмент. Если на него ссылаются записи сегментации,
+// возвращается ErrSegmentInUse; если сегмента нет - sql.ErrNoRows.
+func (r *SegmentRepository) Delete(ctx context.Context, id int64) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM segments WHERE id = $1", id)
+	if isPQError(err, pqForeignKeyViolation) {
+		return ErrSegmentInUse
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete segment %d: %w", id, err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// Register добавляет сегменты, обнаруженные при импорте, и возвращает
+// количество добавленных. Уже зарегистрированные id и коды пропускаются.
+func (r *SegmentRepository) Register(ctx context.Context, segments []*models.Segment) (int, error) {
+	if len(segments) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]int64, len(segments))
+	codes := make([]string, len(segments))
+	names := make([]string, len(segments))
+	for i, segment := range segments {
+		ids[i] = segment.ID
+		codes[i] = segment.Code
+		names[i] = segment.Name
+	}
+
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO segments (id, code, name)
+		SELECT * FROM unnest($1::bigint[], $2::text[], $3::text[])
+		ON CONFLICT DO NOTHING
+	`, pq.Array(ids), pq.Array(codes), pq.Array(names))
+	if err != nil {
+		return 0, fmt.Errorf("failed to register segments: %w", err)
+	}
+
+	n, _ := res.RowsAffected()
+
+	return int(n), nil
+}
+
+func isPQError(err error, code pq.ErrorCode) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == code
+}
@@ -1,35 +1,217 @@
 package repository
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
 	"go-test/internal/models"
+	"go-test/internal/progress"
+)
+
+const (
+	stagingTable = "segmentation_staging"
+
+	// defaultChunkSize используется, если размер чанка не задан в конфигурации
+	defaultChunkSize = 5000
 )
 
 type SegmentationRepository struct {
-	db *sqlx.DB
+	db        *sqlx.DB
+	logger    *slog.Logger
+	chunkSize int
+	// writeOutbox включает запись изменений в segmentation_outbox
+	writeOutbox bool
 }
 
-func NewSegmentationRepository(db *sqlx.DB) *SegmentationRepository {
+// NewSegmentationRepository создает репозиторий сегментации. При
+// writeOutbox каждое изменение записывается в segmentation_outbox в той же
+// транзакции, что и само изменение.
+func NewSegmentationRepository(db *sqlx.DB, logger *slog.Logger, chunkSize int, writeOutbox bool) *SegmentationRepository {
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+
 	return &SegmentationRepository{
-		db: db,
+		db:          db,
+		logger:      logger,
+		chunkSize:   chunkSize,
+		writeOutbox: writeOutbox,
 	}
 }
 
-func (r *SegmentationRepository) InsertOrUpdate(segments []*models.Segmentation) error {
+// InsertOrUpdate сохраняет сегменты в одной транзакции: данные чанками
+// загружаются через COPY во временную таблицу и сливаются в segmentation
+// одним INSERT ... ON CONFLICT на чанк. Это снимает ограничение Postgres
+// в 65535 параметров на запрос и делает импорт атомарным. Строки, у которых
+// adr_segment и segment_id не изменились, не перезаписываются. Повторов
+// address_sap_id в segments быть не должно: их разрешает Validator.Validate.
+// Возвращаются также вставленные и измененные записи.
+func (r *SegmentationRepository) InsertOrUpdate(ctx context.Context, segments []*models.Segmentation) (result models.UpsertResult, changes []models.SegmentChange, err error) {
 	if len(segments) == 0 {
-		return nil
+		return result, nil, nil
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return result, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	createStaging := `
+		CREATE TEMP TABLE ` + stagingTable + ` (
+			address_sap_id VARCHAR(255) NOT NULL,
+			adr_segment VARCHAR(16) NOT NULL,
+			segment_id BIGINT NOT NULL
+		) ON COMMIT DROP
+	`
+	if _, err = tx.ExecContext(ctx, createStaging); err != nil {
+		return result, nil, fmt.Errorf("failed to create staging table: %w", err)
+	}
+
+	for start := 0; start < len(segments); start += r.chunkSize {
+		end := min(start+r.chunkSize, len(segments))
+		chunkStart := time.Now()
+
+		chunk := segments[start:end]
+		if err = copyToStaging(ctx, tx, chunk); err != nil {
+			return result, nil, err
+		}
+
+		var chunkChanges []models.SegmentChange
+		if chunkChanges, err = mergeStaging(ctx, tx, r.writeOutbox); err != nil {
+			return result, nil, err
+		}
+		changes = append(changes, chunkChanges...)
+
+		chunkResult := upsertResult(chunk, chunkChanges)
+		result.Add(chunkResult)
+		progress.FromContext(ctx).BatchUpserted(start, end-start, chunkResult)
+
+		r.logger.DebugContext(ctx, "merged segmentation chunk",
+			"offset", start,
+			"size", end-start,
+			"inserted", chunkResult.Inserted,
+			"updated", chunkResult.Updated,
+			"unchanged", chunkResult.Unchanged,
+			"duration", time.Since(chunkStart),
+		)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return result, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return result, changes, nil
+}
+
+// upsertResult подсчитывает итог слияния чанка без повторов address_sap_id
+func upsertResult(chunk []*models.Segmentation, changes []models.SegmentChange) models.UpsertResult {
+	var result models.UpsertResult
+	for _, c := range changes {
+		if c.Inserted {
+			result.Inserted++
+		} else {
+			result.Updated++
+		}
+	}
+	result.Unchanged = len(chunk) - result.Inserted - result.Updated
+
+	return result
+}
+
+// copyToStaging загружает чанк во временную таблицу через протокол COPY
+func copyToStaging(ctx context.Context, tx *sqlx.Tx, segments []*models.Segmentation) error {
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(stagingTable, "address_sap_id", "adr_segment", "segment_id"))
+	if err != nil {
+		return fmt.Errorf("failed to prepare copy: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, s := range segments {
+		if _, err := stmt.ExecContext(ctx, s.AddressSapID, s.AdrSegment, s.SegmentID); err != nil {
+			return fmt.Errorf("failed to copy segment %q: %w", s.AddressSapID, err)
+		}
+	}
+
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return fmt.Errorf("failed to flush copy: %w", err)
+	}
+
+	return nil
+}
+
+// mergeStaging переносит чанк из временной таблицы в segmentation и очищает её.
+// Признак вставки определяется по xmax = 0 у возвращённых строк, прежние
+// значения берутся из снимка таблицы до слияния. При writeOutbox изменения
+// тем же запросом записываются в segmentation_outbox в порядке address_sap_id.
+func mergeStaging(ctx context.Context, tx *sqlx.Tx, writeOutbox bool) ([]models.SegmentChange, error) {
+	outbox := ""
+	if writeOutbox {
+		outbox = `, outbox AS (
+			INSERT INTO segmentation_outbox (address_sap_id, event_type, payload)
+			SELECT m.address_sap_id, '` + models.OutboxEventSegmentChanged + `', jsonb_build_object(
+				'address_sap_id', m.address_sap_id,
+				'adr_segment', m.adr_segment,
+				'segment_id', m.segment_id,
+				'old_adr_segment', o.adr_segment,
+				'old_segment_id', o.segment_id,
+				'inserted', m.inserted
+			)
+			FROM merged m
+			LEFT JOIN old o USING (address_sap_id)
+			ORDER BY m.address_sap_id
+		)`
 	}
 
 	query := `
-		INSERT INTO segmentation (address_sap_id, adr_segment, segment_id)
-		VALUES (:address_sap_id, :adr_segment, :segment_id)
-		ON CONFLICT (address_sap_id) DO UPDATE 
-		SET adr_segment = EXCLUDED.adr_segment, 
-			segment_id = EXCLUDED.segment_id
+		WITH src AS (
+			SELECT address_sap_id, adr_segment, segment_id FROM ` + stagingTable + `
+		), old AS (
+			SELECT s.address_sap_id, s.adr_segment, s.segment_id
+			FROM segmentation s
+			JOIN src USING (address_sap_id)
+		), merged AS (
+			INSERT INTO segmentation (address_sap_id, adr_segment, segment_id)
+			SELECT address_sap_id, adr_segment, segment_id FROM src
+			ON CONFLICT (address_sap_id) DO UPDATE
+			SET adr_segment = EXCLUDED.adr_segment,
+				segment_id = EXCLUDED.segment_id,
+				updated_at = now()
+			WHERE segmentation.adr_segment IS DISTINCT FROM EXCLUDED.adr_segment
+				OR segmentation.segment_id IS DISTINCT FROM EXCLUDED.segment_id
+			RETURNING address_sap_id, adr_segment, segment_id, (xmax = 0) AS inserted
+		)` + outbox + `
+		SELECT
+			m.address_sap_id,
+			m.adr_segment,
+			m.segment_id,
+			m.inserted,
+			o.adr_segment AS old_adr_segment,
+			o.segment_id AS old_segment_id
+		FROM merged m
+		LEFT JOIN old o USING (address_sap_id)
+		ORDER BY m.address_sap_id
 	`
 
-	_, err := r.db.NamedExec(query, segments)
-	return err
+	var changes []models.SegmentChange
+	if err := tx.SelectContext(ctx, &changes, query); err != nil {
+		return nil, fmt.Errorf("failed to merge staging table: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "TRUNCATE "+stagingTable); err != nil {
+		return nil, fmt.Errorf("failed to truncate staging table: %w", err)
+	}
+
+	return changes, nil
 }
 
 func (r *SegmentationRepository) GetByAddressSapID(addressSapID string) (*models.Segmentation, error) {
@@ -43,3 +225,21 @@ func (r *SegmentationRepository) GetAll() ([]*models.Segmentation, error) {
 	err := r.db.Select(&segments, "SELECT * FROM segmentation")
 	return segments, err
 }
+
+// Version возвращает число записей и время последнего изменения без
+// чтения самих записей
+func (r *SegmentationRepository) Version(ctx context.Context) (models.SegmentationVersion, error) {
+	var version models.SegmentationVersion
+	err := r.db.GetContext(ctx, &version, `
+		SELECT count(*) AS rows, coalesce(max(updated_at), 'epoch'::timestamptz) AS last_modified
+		FROM segmentation
+	`)
+	if err != nil {
+		return version, fmt.Errorf("failed to get segmentation version: %w", err)
+	}
+	if version.Rows == 0 {
+		version.LastModified = time.Time{}
+	}
+
+	return version, nil
+}
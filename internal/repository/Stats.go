@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"go-test/internal/models"
+)
+
+// prefixExpr выделяет префикс address_sap_id: буквы до первого дефиса
+// включительно (SAP-, SEG-, ADR-); у идентификаторов без префикса - пусто
+const prefixExpr = `coalesce(substring(address_sap_id from '^[A-Za-z]+-'), '')`
+
+// StatsRepository считает распределение адресов по сегментам
+type StatsRepository struct {
+	db *sqlx.DB
+}
+
+func NewStatsRepository(db *sqlx.DB) *StatsRepository {
+	return &StatsRepository{db: db}
+}
+
+// Snapshot сохраняет текущее распределение по сегментам для импорта
+func (r *StatsRepository) Snapshot(ctx context.Context, importID int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO import_segment_counts (import_id, prefix, adr_segment, segment_id, count)
+		SELECT $1, `+prefixExpr+`, adr_segment, segment_id, count(*)
+		FROM segmentation
+		GROUP BY 2, 3, 4
+		ON CONFLICT DO NOTHING
+	`, importID)
+	if err != nil {
+		return fmt.Errorf("failed to save segment counts for import %d: %w", importID, err)
+	}
+
+	return nil
+}
+
+// Stats возвращает текущее распределение и распределения после последних
+// imports успешных импортов с изменением относительно предыдущего.
+// byPrefix разбивает счетчики по префиксу address_sap_id.
+func (r *StatsRepository) Stats(ctx context.Context, imports int, byPrefix bool) (*models.SegmentStats, error) {
+	segments := make([]models.SegmentCount, 0)
+	err := r.db.SelectContext(ctx, &segments, `
+		SELECT CASE WHEN $1 THEN `+prefixExpr+` ELSE '' END AS prefix,
+			s.adr_segment, s.segment_id, coalesce(d.name, '') AS name, count(*) AS count
+		FROM segmentation s
+		LEFT JOIN segments d ON d.id = s.segment_id
+		GROUP BY 1, 2, 3, 4
+		ORDER BY 1, 3, 2
+	`, byPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count segments: %w", err)
+	}
+
+	stats := &models.SegmentStats{
+		Segments: segments,
+		Imports:  make([]models.ImportSegmentStats, 0),
+	}
+	stats.Total, stats.Prefixes = totals(segments, byPrefix)
+
+	if imports <= 0 {
+		return stats, nil
+	}
+
+	// Один лишний импорт нужен для изменения у самого старого из выбранных
+	var history []*models.Import
+	err = r.db.SelectContext(ctx, &history, `
+		SELECT * FROM imports i
+		WHERE status = $1
+			AND EXISTS (SELECT 1 FROM import_segment_counts c WHERE c.import_id = i.id)
+		ORDER BY id DESC
+		LIMIT $2
+	`, models.ImportStatusSucceeded, imports+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import history: %w", err)
+	}
+	if len(history) == 0 {
+		return stats, nil
+	}
+
+	ids := make([]int64, len(history))
+	for i, imp := range history {
+		ids[i] = imp.ID
+	}
+
+	var rows []struct {
+		ImportID int64 `db:"import_id"`
+		models.SegmentCount
+	}
+	err = r.db.SelectContext(ctx, &rows, `
+		SELECT c.import_id, CASE WHEN $2 THEN c.prefix ELSE '' END AS prefix,
+			c.adr_segment, c.segment_id, coalesce(d.name, '') AS name, sum(c.count)::bigint AS count
+		FROM import_segment_counts c
+		LEFT JOIN segments d ON d.id = c.segment_id
+		WHERE c.import_id = ANY($1)
+		GROUP BY 1, 2, 3, 4, 5
+		ORDER BY 2, 4, 3
+	`, pq.Array(ids), byPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get segment counts history: %w", err)
+	}
+
+	counts := make(map[int64][]models.SegmentCount, len(history))
+	for _, row := range rows {
+		counts[row.ImportID] = append(counts[row.ImportID], row.SegmentCount)
+	}
+
+	for i, imp := range history[:min(imports, len(history))] {
+		item := models.ImportSegmentStats{
+			ImportID:   imp.ID,
+			Mode:       imp.Mode,
+			FinishedAt: imp.FinishedAt,
+			Segments:   counts[imp.ID],
+		}
+
+		if i+1 < len(history) {
+			prev := counts[history[i+1].ID]
+			item.Segments = withDeltas(item.Segments, prev)
+
+			delta := sumCounts(item.Segments) - sumCounts(prev)
+			item.TotalDelta = &delta
+		}
+		if item.Segments == nil {
+			item.Segments = make([]models.SegmentCount, 0)
+		}
+
+		item.Total, item.Prefixes = totals(item.Segments, byPrefix)
+		stats.Imports = append(stats.Imports, item)
+	}
+
+	return stats, nil
+}
+
+// withDeltas заполняет изменение счетчиков относительно prev. Сегменты,
+// исчезнувшие после prev, добавляются с нулевым количеством.
+func withDeltas(current, prev []models.SegmentCount) []models.SegmentCount {
+	type key struct {
+		prefix     string
+		adrSegment string
+		segmentID  int64
+	}
+
+	prevCounts := make(map[key]models.SegmentCount, len(prev))
+	for _, count := range prev {
+		prevCounts[key{count.Prefix, count.AdrSegment, count.SegmentID}] = count
+	}
+
+	result := make([]models.SegmentCount, 0, len(current))
+	for _, count := range current {
+		k := key{count.Prefix, count.AdrSegment, count.SegmentID}
+		delta := count.Count - prevCounts[k].Count
+		count.Delta = &delta
+		delete(prevCounts, k)
+		result = append(result, count)
+	}
+
+	if len(prevCounts) == 0 {
+		return result
+	}
+
+	for _, count := range prevCounts {
+		delta := -count.Count
+		count.Count = 0
+		count.Delta = &delta
+		result = append(result, count)
+	}
+
+	slices.SortFunc(result, func(a, b models.SegmentCount) int {
+		return cmp.Or(
+			cmp.Compare(a.Prefix, b.Prefix),
+			cmp.Compare(a.SegmentID, b.SegmentID),
+			cmp.Compare(a.AdrSegment, b.AdrSegment),
+		)
+	})
+
+	return result
+}
+
+// totals возвращает общее количество и, если byPrefix, итоги по префиксам
+func totals(counts []models.SegmentCount, byPrefix bool) (int64, map[string]int64) {
+	var prefixes map[string]int64
+	if byPrefix {
+		prefixes = make(map[string]int64)
+		for _, count := range counts {
+			prefixes[count.Prefix] += count.Count
+		}
+	}
+
+	return sumCounts(counts), prefixes
+}
+
+func sumCounts(counts []models.SegmentCount) int64 {
+	var total int64
+	for _, count := range counts {
+		total += count.Count
+	}
+
+	return total
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"go-test/internal/models"
+)
+
+// WebhookRepository хранит журнал доставки уведомлений
+type WebhookRepository struct {
+	db *sqlx.DB
+}
+
+func NewWebhookRepository(db *sqlx.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+// SaveDelivery записывает попытку доставки события
+func (r *WebhookRepository) SaveDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	err := r.db.GetContext(ctx, d, `
+		INSERT INTO webhook_deliveries
+			(event_id, event_type, url, attempt, status_code, error, duration_ms, succeeded)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING *
+	`, d.EventID, d.EventType, d.URL, d.Attempt, d.StatusCode, d.Error, d.DurationMs, d.Succeeded)
+	if err != nil {
+		return fmt.Errorf("failed to save webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+// GetDeliveries возвращает последние попытки доставки, новые первыми.
+// Непустой eventID оставляет попытки только этого события.
+func (r *WebhookRepository) GetDeliveries(eventID string, limit int) ([]*models.WebhookDelivery, error) {
+	deliveries := make([]*models.WebhookDelivery, 0)
+	err := r.db.Select(&deliveries, `
+		SELECT * FROM webhook_deliveries
+		WHERE $1 = '' OR event_id = $1
+		ORDER BY id DESC
+		LIMIT $2
+	`, eventID, limit)
+	return deliveries, err
+}
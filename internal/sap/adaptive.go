@@ -0,0 +1,140 @@
+package sap
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-test/pkg/config"
+)
+
+// decreaseCooldown - минимальный интервал между снижениями частоты, чтобы
+// параллельные запросы не обрушили ее от одного всплеска задержек
+const decreaseCooldown = time.Second
+
+// adaptiveThrottle регулирует частоту запросов лимитера по принципу AIMD:
+// частота растет на фиксированный шаг после быстрых ответов и
+// уменьшается в разы при росте задержек и ответах 429/503.
+type adaptiveThrottle struct {
+	limiter *rateLimiter
+	logger  *slog.Logger
+
+	enabled       bool
+	minRate       float64
+	maxRate       float64
+	increaseStep  float64
+	decreaseRatio float64
+	latencyTarget time.Duration
+
+	mu           sync.Mutex
+	lastDecrease time.Time
+}
+
+func newAdaptiveThrottle(cfg *config.Config, limiter *rateLimiter, logger *slog.Logger) *adaptiveThrottle {
+	t := &adaptiveThrottle{
+		limiter:       limiter,
+		logger:        logger,
+		enabled:       cfg.Connection.AdaptiveRate,
+		minRate:       cfg.Connection.RateMin,
+		maxRate:       cfg.Connection.RateMax,
+		increaseStep:  cfg.Connection.RateIncrease,
+		decreaseRatio: cfg.Connection.RateDecrease,
+		latencyTarget: cfg.Connection.LatencyTarget,
+	}
+
+	t.Reset(limiter.Rate())
+
+	return t
+}
+
+// Reset устанавливает начальную частоту запросов, в адаптивном режиме -
+// в пределах [minRate, maxRate]
+func (t *adaptiveThrottle) Reset(rate float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.enabled {
+		// Без ограничения регулировать нечего, поэтому стартуем с максимума
+		if rate <= 0 || rate > t.maxRate {
+			rate = t.maxRate
+		}
+		rate = max(rate, t.minRate)
+	}
+
+	t.limiter.SetRate(rate)
+	requestRateGauge.Set(t.limiter.Rate())
+}
+
+// OnResponse учитывает задержку успешного ответа
+func (t *adaptiveThrottle) OnResponse(latency time.Duration) {
+	if !t.enabled {
+		return
+	}
+
+	if t.latencyTarget > 0 && latency > t.latencyTarget {
+		t.decrease("latency above target", "latency", latency)
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	rate := min(t.limiter.Rate()+t.increaseStep, t.maxRate)
+	t.limiter.SetRate(rate)
+	requestRateGauge.Set(rate)
+
+	t.logger.Debug("SAP request rate increased", "rate", rate, "latency", latency)
+}
+
+// OnThrottle учитывает ответ 429/503. Если SAP передал Retry-After,
+// запросы приостанавливаются на указанное время.
+func (t *adaptiveThrottle) OnThrottle(status int, retryAfter time.Duration) {
+	throttledTotal.Inc()
+
+	if retryAfter > 0 {
+		t.limiter.Pause(retryAfter)
+	}
+
+	if !t.enabled {
+		return
+	}
+
+	t.decrease("throttled by SAP API", "status", status, "retry_after", retryAfter)
+}
+
+func (t *adaptiveThrottle) decrease(reason string, args ...any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if time.Since(t.lastDecrease) < decreaseCooldown {
+		return
+	}
+	t.lastDecrease = time.Now()
+
+	oldRate := t.limiter.Rate()
+	rate := max(oldRate*t.decreaseRatio, t.minRate)
+	t.limiter.SetRate(rate)
+	requestRateGauge.Set(rate)
+
+	t.logger.Warn("SAP request rate decreased",
+		append([]any{"reason", reason, "old_rate", oldRate, "rate", rate}, args...)...)
+}
+
+// parseRetryAfter разбирает заголовок Retry-After в секундах или в виде HTTP-даты
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+
+	if at, err := http.ParseTime(header); err == nil {
+		return max(time.Until(at), 0)
+	}
+
+	return 0
+}
@@ -0,0 +1,209 @@
+package sap
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go-test/pkg/config"
+)
+
+// Способы аутентификации в SAP API
+const (
+	AuthNone   = "none"
+	AuthBasic  = "basic"
+	AuthBearer = "bearer"
+	AuthOAuth2 = "oauth2"
+)
+
+// tokenRefreshMargin - за сколько до истечения токен OAuth2 обновляется заранее
+const tokenRefreshMargin = 30 * time.Second
+
+// authenticator добавляет учетные данные к запросу в SAP API
+type authenticator interface {
+	Authorize(ctx context.Context, req *http.Request) error
+	// Invalidate сбрасывает закэшированные учетные данные после ответа 401
+	Invalidate()
+}
+
+// newAuthenticator создает способ аутентификации, заданный в конфигурации
+func newAuthenticator(cfg *config.Config, httpClient *http.Client) (authenticator, error) {
+	switch cfg.Connection.AuthType {
+	case AuthNone:
+		return noAuth{}, nil
+	case AuthBasic, "":
+		auth := base64.StdEncoding.EncodeToString([]byte(cfg.Connection.AuthLoginPwd.Value()))
+		return staticAuth{header: "Basic " + auth}, nil
+	case AuthBearer:
+		if !cfg.Connection.AuthToken.IsSet() {
+			return nil, errors.New("CONN_AUTH_TOKEN is required for bearer authentication")
+		}
+		return staticAuth{header: "Bearer " + cfg.Connection.AuthToken.Value()}, nil
+	case AuthOAuth2:
+		if cfg.Connection.OAuthTokenURL == "" || cfg.Connection.OAuthClientID == "" {
+			return nil, errors.New("CONN_OAUTH_TOKEN_URL and CONN_OAUTH_CLIENT_ID are required for oauth2 authentication")
+		}
+		return &oauth2Auth{
+			httpClient:   httpClient,
+			tokenURL:     cfg.Connection.OAuthTokenURL,
+			clientID:     cfg.Connection.OAuthClientID,
+			clientSecret: cfg.Connection.OAuthClientSecret.Value(),
+			scopes:       cfg.Connection.OAuthScopes,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown SAP auth type %q", cfg.Connection.AuthType)
+	}
+}
+
+// noAuth не добавляет учетных данных
+type noAuth struct{}
+
+func (noAuth) Authorize(context.Context, *http.Request) error { return nil }
+
+func (noAuth) Invalidate() {}
+
+// staticAuth добавляет неизменный заголовок Authorization (Basic или Bearer)
+type staticAuth struct {
+	header string
+}
+
+func (a staticAuth) Authorize(_ context.Context, req *http.Request) error {
+	req.Header.Set("Authorization", a.header)
+	return nil
+}
+
+func (staticAuth) Invalidate() {}
+
+// oauth2Auth получает токен по OAuth2 client credentials и кэширует его
+// до истечения срока действия
+type oauth2Auth struct {
+	httpClient   *http.Client
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scopes       []string
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func (a *oauth2Auth) Authorize(ctx context.Context, req *http.Request) error {
+	token, err := a.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (a *oauth2Auth) Invalidate() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.token = ""
+}
+
+// accessToken возвращает закэшированный токен или запрашивает новый.
+// Параллельные запросы ждут одного обращения к серверу токенов.
+func (a *oauth2Auth) accessToken(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && (a.expiry.IsZero() || time.Until(a.expiry) > tokenRefreshMargin) {
+		return a.token, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if len(a.scopes) > 0 {
+		form.Set("scope", strings.Join(a.scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("error creating token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(a.clientID), url.QueryEscape(a.clientSecret))
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error requesting OAuth2 token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		return "", fmt.Errorf("error response from token endpoint: status=%d, body=%s", resp.StatusCode, string(body))
+	}
+
+	var token tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("error decoding OAuth2 token: %w", err)
+	}
+
+	if token.AccessToken == "" {
+		return "", errors.New("token endpoint returned empty access_token")
+	}
+
+	a.token = token.AccessToken
+	a.expiry = time.Time{}
+	if token.ExpiresIn > 0 {
+		a.expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+
+	return a.token, nil
+}
+
+// newTLSConfig настраивает TLS для взаимной аутентификации: клиентский
+// сертификат и собственный набор корневых сертификатов
+func newTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	conn := cfg.Connection
+	if conn.TLSCertFile == "" && conn.TLSKeyFile == "" && conn.TLSCAFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if conn.TLSCertFile != "" || conn.TLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(conn.TLSCertFile, conn.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if conn.TLSCAFile != "" {
+		pem, err := os.ReadFile(conn.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", conn.TLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
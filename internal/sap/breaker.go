@@ -0,0 +1,207 @@
+package sap
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go-test/pkg/config"
+)
+
+// ErrCircuitOpen возвращается без обращения к SAP API, пока автомат разомкнут
+var ErrCircuitOpen = errors.New("SAP API circuit breaker is open")
+
+// BreakerState - состояние автоматического выключателя
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerHalfOpen
+	BreakerOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerHalfOpen:
+		return "half-open"
+	case BreakerOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// circuitBreaker размыкается, когда доля неудачных запросов в окне
+// превышает порог, и после паузы пропускает пробные запросы
+type circuitBreaker struct {
+	logger *slog.Logger
+
+	enabled          bool
+	failureRatio     float64
+	minRequests      int
+	window           time.Duration
+	coolDown         time.Duration
+	halfOpenRequests int
+
+	mu          sync.Mutex
+	state       BreakerState
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	halfOpenAt  time.Time
+	probes      int
+}
+
+func newCircuitBreaker(cfg *config.Config, logger *slog.Logger) *circuitBreaker {
+	b := &circuitBreaker{
+		logger:           logger,
+		enabled:          cfg.Connection.BreakerEnabled,
+		failureRatio:     cfg.Connection.BreakerFailureRatio,
+		minRequests:      max(cfg.Connection.BreakerMinRequests, 1),
+		window:           cfg.Connection.BreakerWindow,
+		coolDown:         cfg.Connection.BreakerCoolDown,
+		halfOpenRequests: max(cfg.Connection.BreakerHalfOpenRequests, 1),
+		windowStart:      time.Now(),
+	}
+	b.reportState()
+
+	return b
+}
+
+// Allow проверяет, можно ли выполнить запрос
+func (b *circuitBreaker) Allow() error {
+	if !b.enabled {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.coolDown {
+			return ErrCircuitOpen
+		}
+		b.setState(BreakerHalfOpen)
+		b.probes = 1
+		return nil
+	case BreakerHalfOpen:
+		// Пробы, результат которых так и не пришел, не должны держать
+		// автомат в полуоткрытом состоянии: через паузу пробуем снова
+		if b.probes >= b.halfOpenRequests && time.Since(b.halfOpenAt) >= b.coolDown {
+			b.probes = 0
+			b.halfOpenAt = time.Now()
+		}
+		if b.probes >= b.halfOpenRequests {
+			return ErrCircuitOpen
+		}
+		b.probes++
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Release возвращает пробу, разрешенную Allow, если запрос не был выполнен
+// и Record для него не вызывается (ошибка до отправки, отмена ctx)
+func (b *circuitBreaker) Release() {
+	if !b.enabled {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+// Record учитывает результат запроса
+func (b *circuitBreaker) Record(success bool) {
+	if !b.enabled {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerHalfOpen:
+		if success {
+			b.setState(BreakerClosed)
+		} else {
+			b.setState(BreakerOpen)
+		}
+	case BreakerClosed:
+		if b.window > 0 && time.Since(b.windowStart) > b.window {
+			b.resetCounts()
+		}
+
+		b.requests++
+		if !success {
+			b.failures++
+		}
+
+		if b.requests >= b.minRequests && float64(b.failures)/float64(b.requests) >= b.failureRatio {
+			b.setState(BreakerOpen)
+		}
+	}
+}
+
+// State возвращает текущее состояние
+func (b *circuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Разомкнутый автомат после паузы готов к пробному запросу
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.coolDown {
+		return BreakerHalfOpen
+	}
+
+	return b.state
+}
+
+func (b *circuitBreaker) setState(state BreakerState) {
+	if b.state == state {
+		return
+	}
+
+	b.logger.Warn("SAP API circuit breaker state changed",
+		"from", b.state.String(),
+		"to", state.String(),
+		"requests", b.requests,
+		"failures", b.failures,
+	)
+
+	b.state = state
+	b.resetCounts()
+	switch state {
+	case BreakerOpen:
+		b.openedAt = time.Now()
+	case BreakerHalfOpen:
+		b.halfOpenAt = time.Now()
+	}
+	b.reportState()
+}
+
+func (b *circuitBreaker) resetCounts() {
+	b.windowStart = time.Now()
+	b.requests = 0
+	b.failures = 0
+	b.probes = 0
+}
+
+func (b *circuitBreaker) reportState() {
+	for _, state := range []BreakerState{BreakerClosed, BreakerHalfOpen, BreakerOpen} {
+		value := 0.0
+		if state == b.state {
+			value = 1
+		}
+		breakerState.With(state.String()).Set(value)
+	}
+}
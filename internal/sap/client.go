@@ -1,50 +1,120 @@
 package sap
 
 import (
-	"encoding/base64"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"math/rand"
 	"net/http"
+	"strconv"
+	"sync/atomic"
 	"time"
 
+	"go-test/internal/logutil"
 	"go-test/internal/models"
+	"go-test/internal/progress"
 	"go-test/pkg/config"
 )
 
+// maxErrorBodySize ограничивает размер тела ошибки, попадающего в лог
+const maxErrorBodySize = 4096
+
+// errUnauthorized возвращается, если SAP API отклонил учетные данные
+var errUnauthorized = errors.New("SAP API returned 401 Unauthorized")
+
 type Client struct {
-	httpClient  *http.Client
-	baseURL     string
-	authHeader  string
-	userAgent   string
-	batchSize   int
-	interval    time.Duration
-	logger      *slog.Logger
-	useTestData bool
+	httpClient        *http.Client
+	baseURL           string
+	auth              authenticator
+	userAgent         string
+	batchSize         atomic.Int64
+	concurrency       int
+	maxRetries        int
+	limiter           *rateLimiter
+	throttle          *adaptiveThrottle
+	breaker           *circuitBreaker
+	changedSinceParam string
+	pagination        Pagination
+	keysetParam       string
+	logger            *slog.Logger
+	useTestData       bool
+}
+
+func NewClient(cfg *config.Config, logger *slog.Logger) (*Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	httpClient := &http.Client{
+		Timeout:   cfg.Connection.Timeout,
+		Transport: transport,
+	}
+
+	auth, err := newAuthenticator(cfg, httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	limiter := newRateLimiter(requestRate(cfg), cfg.Connection.RateBurst)
+
+	c := &Client{
+		httpClient:        httpClient,
+		baseURL:           cfg.Connection.URI,
+		auth:              auth,
+		userAgent:         cfg.Connection.UserAgent,
+		concurrency:       max(cfg.Connection.Concurrency, 1),
+		maxRetries:        max(cfg.Connection.MaxRetries, 0),
+		limiter:           limiter,
+		throttle:          newAdaptiveThrottle(cfg, limiter, logger),
+		breaker:           newCircuitBreaker(cfg, logger),
+		changedSinceParam: cfg.Connection.ChangedSinceParam,
+		pagination:        Pagination(cfg.Connection.Pagination),
+		keysetParam:       cfg.Connection.KeysetParam,
+		logger:            logger,
+		useTestData:       cfg.Import.UseTestData,
+	}
+	c.batchSize.Store(int64(cfg.Import.BatchSize))
+
+	return c, nil
 }
 
-type Response struct {
-	Items []models.Segmentation `json:"items"`
+// Reload применяет настройки, которые можно менять без перезапуска: размер
+// страницы и частоту запросов. Загрузка, которая уже идет, продолжается со
+// старым размером страницы.
+func (c *Client) Reload(cfg *config.Config) {
+	c.batchSize.Store(int64(cfg.Import.BatchSize))
+	c.throttle.Reset(requestRate(cfg))
+
+	c.logger.Info("SAP client settings reloaded",
+		"batch_size", cfg.Import.BatchSize,
+		"rate", c.limiter.Rate(),
+	)
 }
 
-func NewClient(cfg *config.Config, logger *slog.Logger) *Client {
-	logger.Info("config:", "cfg", cfg)
-	auth := base64.StdEncoding.EncodeToString([]byte(cfg.Connection.AuthLoginPwd))
-
-	return &Client{
-		httpClient: &http.Client{
-			Timeout: cfg.Connection.Timeout,
-		},
-		baseURL:     cfg.Connection.URI,
-		authHeader:  fmt.Sprintf("Basic %s", auth),
-		userAgent:   cfg.Connection.UserAgent,
-		batchSize:   cfg.Import.BatchSize,
-		interval:    cfg.Connection.Interval,
-		logger:      logger,
-		useTestData: cfg.Import.UseTestData,
+// BreakerState возвращает состояние автоматического выключателя SAP API
+func (c *Client) BreakerState() BreakerState {
+	return c.breaker.State()
+}
+
+// requestRate возвращает допустимую частоту запросов в секунду. Если
+// CONN_RATE_LIMIT не задан, частота выводится из CONN_INTERVAL.
+func requestRate(cfg *config.Config) float64 {
+	if cfg.Connection.RateLimit > 0 {
+		return cfg.Connection.RateLimit
+	}
+
+	if cfg.Connection.Interval > 0 {
+		return float64(time.Second) / float64(cfg.Connection.Interval)
 	}
+
+	return 0
 }
 
 // generateTestData создает тестовые данные для разработки и тестирования
@@ -66,138 +136,260 @@ func (c *Client) generateTestData() []*models.Segmentation {
 		testData[i] = &models.Segmentation{
 			AddressSapID: fmt.Sprintf("%s%03d", prefix, i+1),
 			AdrSegment:   segments[segmentIdx],
-			SegmentID:    int64(1000 + i),
+			SegmentID:    int64(1000 + segmentIdx),
 		}
 	}
 
 	return testData
 }
 
-func (c *Client) FetchSegmentation() ([]*models.Segmentation, error) {
+// FetchSegmentation загружает сегментацию из SAP. Если since не нулевой,
+// запрашиваются только записи, измененные начиная с этого момента.
+// Идентификатор запроса из ctx передается в SAP в заголовке X-Request-ID.
+func (c *Client) FetchSegmentation(ctx context.Context, since time.Time) ([]*models.Segmentation, error) {
 	if c.useTestData {
-		c.logger.Info("using test data as configured by USE_TEST_DATA=true")
+		c.logger.InfoContext(ctx, "using test data as configured by USE_TEST_DATA=true")
 		return c.generateTestData(), nil
 	}
 
-	var allSegments []*models.Segmentation
-	offset := 0
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	c.logger.Info("testing connection to SAP API", "url", c.baseURL)
-	testURL := fmt.Sprintf("%s?p_limit=1&p_offset=0", c.baseURL)
-	testReq, err := http.NewRequest(http.MethodGet, testURL, nil)
-	if err != nil {
-		c.logger.Error("error creating test request", "error", err.Error())
-		return nil, fmt.Errorf("error creating test request: %w", err)
+	c.logger.InfoContext(ctx, "testing connection to SAP API", "url", c.baseURL)
+	if _, err := c.fetchPage(ctx, c.offsetURL(1, 0, since)); err != nil {
+		// Если получена ошибка 401 Unauthorized, возвращаем тестовые данные
+		if errors.Is(err, errUnauthorized) {
+			c.logger.WarnContext(ctx, "SAP API is not available, using test data", "error", err.Error())
+			return c.generateTestData(), nil
+		}
+
+		c.logger.ErrorContext(ctx, "error connecting to SAP API", "error", err.Error())
+		return nil, fmt.Errorf("error connecting to SAP API: %w", err)
 	}
 
-	testReq.Header.Set("Authorization", c.authHeader)
-	testReq.Header.Set("User-Agent", c.userAgent)
-	testResp, testErr := c.httpClient.Do(testReq)
+	var allSegments []*models.Segmentation
+	var err error
+	if c.pagination == PaginationOffset {
+		allSegments, err = c.fetchPages(ctx, since)
+	} else {
+		allSegments, err = c.fetchSequential(ctx, since)
+	}
+	if err != nil {
+		// Если получена ошибка 401 Unauthorized на этом этапе, также используем тестовые данные
+		if errors.Is(err, errUnauthorized) {
+			c.logger.WarnContext(ctx, "SAP API is not available during fetching, using test data", "error", err.Error())
+			return c.generateTestData(), nil
+		}
 
-	if testErr != nil {
-		c.logger.Error("error connecting to SAP API", "error", testErr.Error())
-		return nil, fmt.Errorf("error connecting to SAP API: %w", testErr)
+		return nil, err
 	}
 
-	if testResp != nil && testResp.Body != nil {
-		defer testResp.Body.Close()
+	allSegments, removed := dedupe(allSegments)
+	if removed > 0 {
+		c.logger.WarnContext(ctx, "removed repeated records returned by SAP API", "duplicates", removed)
 	}
 
-	if testResp != nil && testResp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(testResp.Body)
-		c.logger.Error("SAP API returned error status",
-			"status", testResp.StatusCode,
-			"body", string(bodyBytes),
-		)
+	c.logger.InfoContext(ctx, "finished fetching data from SAP API", "total_segments", len(allSegments))
 
-		// Если получена ошибка 401 Unauthorized, возвращаем тестовые данные
-		if testResp.StatusCode == http.StatusUnauthorized {
-			c.logger.Warn("SAP API is not available, using test data",
-				"error", nil,
-				"status", testResp.StatusCode,
-			)
-			return c.generateTestData(), nil
-		}
+	return allSegments, nil
+}
 
-		return nil, fmt.Errorf("error response from SAP API: status=%d, body=%s",
-			testResp.StatusCode, string(bodyBytes))
-	}
+type pageResult struct {
+	index int
+	page  *page
+	err   error
+}
+
+// fetchPages загружает страницы параллельно, не более concurrency запросов
+// одновременно. Частота запросов ограничивается общим лимитером. Страницы
+// запрашиваются с опережением до последней (см. page.Last), а результат
+// собирается в порядке смещений.
+func (c *Client) fetchPages(ctx context.Context, since time.Time) ([]*models.Segmentation, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	batchSize := int(c.batchSize.Load())
+
+	// Буфер на все одновременные запросы, чтобы горутины не блокировались
+	// после досрочного выхода
+	results := make(chan pageResult, c.concurrency)
+
+	var (
+		allSegments []*models.Segmentation
+		pending     = make(map[int]pageResult)
+		next        int  // следующая страница для запроса
+		emit        int  // следующая страница для добавления в результат
+		inFlight    int  // запросов в работе
+		last        = -1 // количество страниц с данными, -1 пока неизвестно
+	)
 
 	for {
-		c.logger.Info("fetching data from SAP API",
-			"url", c.baseURL,
-			"offset", offset,
-			"limit", c.batchSize,
-		)
+		for inFlight < c.concurrency && (last < 0 || next < last) {
+			go func(index int) {
+				p, err := c.fetchPage(ctx, c.offsetURL(batchSize, index*batchSize, since))
+				results <- pageResult{index: index, page: p, err: err}
+			}(next)
+			next++
+			inFlight++
+		}
 
-		url := fmt.Sprintf("%s?p_limit=%d&p_offset=%d", c.baseURL, c.batchSize, offset)
+		if inFlight == 0 {
+			return allSegments, nil
+		}
 
-		req, err := http.NewRequest(http.MethodGet, url, nil)
-		if err != nil {
-			return nil, fmt.Errorf("error creating request: %w", err)
+		res := <-results
+		inFlight--
+
+		if res.err != nil {
+			if last >= 0 && res.index >= last {
+				// Ошибка на странице после конца данных не влияет на результат
+				continue
+			}
+			return nil, res.err
 		}
 
-		req.Header.Set("Authorization", c.authHeader)
-		req.Header.Set("User-Agent", c.userAgent)
+		if res.page.Last() {
+			end := res.index
+			if len(res.page.Segments) > 0 {
+				end++
+			}
+			if last < 0 || end < last {
+				last = end
+			}
+		}
+		pending[res.index] = res
 
-		resp, err := c.httpClient.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("error making request: %w", err)
+		for {
+			page, ok := pending[emit]
+			if !ok || (last >= 0 && emit >= last) {
+				break
+			}
+			delete(pending, emit)
+			allSegments = append(allSegments, page.page.Segments...)
+			progress.FromContext(ctx).PageFetched(len(page.page.Segments))
+			emit++
 		}
 
-		defer resp.Body.Close()
+		if last >= 0 && emit >= last {
+			return allSegments, nil
+		}
+	}
+}
 
-		if resp.StatusCode != http.StatusOK {
-			bodyBytes, _ := io.ReadAll(resp.Body)
+// fetchPage загружает и потоково разбирает одну страницу
+func (c *Client) fetchPage(ctx context.Context, reqURL string) (*page, error) {
+	c.logger.InfoContext(ctx, "fetching data from SAP API",
+		"url", reqURL,
+		"rate", c.limiter.Rate(),
+	)
 
-			// Если получена ошибка 401 Unauthorized на этом этапе, также используем тестовые данные
-			if resp.StatusCode == http.StatusUnauthorized {
-				c.logger.Warn("SAP API is not available during fetching, using test data",
-					"error", nil,
-					"status", resp.StatusCode,
-				)
-				return c.generateTestData(), nil
-			}
+	resp, err := c.get(ctx, reqURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	p, err := decodePage(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding page %s: %w", reqURL, err)
+	}
+
+	return p, nil
+}
 
-			return nil, fmt.Errorf("error response from SAP API: status=%d, body=%s",
-				resp.StatusCode, string(bodyBytes))
+// get выполняет GET-запрос с учетом лимитера и автоматического выключателя.
+// Ответы 429/503 снижают частоту запросов и повторяются не более maxRetries раз,
+// после ответа 401 учетные данные обновляются и запрос повторяется один раз.
+// При успехе тело ответа закрывает вызывающий.
+func (c *Client) get(ctx context.Context, reqURL string) (*http.Response, error) {
+	reauthorized := false
+
+	for attempt := 0; ; attempt++ {
+		// Сначала лимитер: ожидание не должно занимать пробу полуоткрытого автомата
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, err
 		}
 
-		bodyBytes, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("error reading response body: %w", err)
+		if err := c.breaker.Allow(); err != nil {
+			return nil, err
 		}
 
-		if len(bodyBytes) == 0 || string(bodyBytes) == "[]" || string(bodyBytes) == "{}" {
-			break
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+		if err != nil {
+			c.breaker.Release()
+			return nil, fmt.Errorf("error creating request: %w", err)
 		}
 
-		var response Response
-		if err := json.Unmarshal(bodyBytes, &response); err != nil {
-			return nil, fmt.Errorf("error unmarshaling response: %w", err)
+		if err := c.auth.Authorize(ctx, req); err != nil {
+			c.breaker.Release()
+			return nil, fmt.Errorf("error authorizing request: %w", err)
+		}
+		req.Header.Set("User-Agent", c.userAgent)
+		if requestID := logutil.RequestID(ctx); requestID != "" {
+			req.Header.Set(logutil.RequestIDHeader, requestID)
 		}
 
-		if len(response.Items) == 0 {
-			break
+		start := time.Now()
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			requestsTotal.With("error").Inc()
+			if ctx.Err() == nil {
+				c.breaker.Record(false)
+			} else {
+				c.breaker.Release()
+			}
+			return nil, fmt.Errorf("error making request: %w", err)
 		}
 
-		segments := make([]*models.Segmentation, 0)
-		for _, item := range response.Items {
-			segments = append(segments, &models.Segmentation{
-				AddressSapID: item.AddressSapID,
-				AdrSegment:   item.AdrSegment,
-				SegmentID:    item.SegmentID,
-			})
+		latency := time.Since(start)
+		requestsTotal.With(strconv.Itoa(resp.StatusCode)).Inc()
+
+		// Ошибки 5xx говорят о недоступности SAP, остальные ответы - о его работоспособности
+		c.breaker.Record(resp.StatusCode < http.StatusInternalServerError)
+
+		if resp.StatusCode == http.StatusOK {
+			c.throttle.OnResponse(latency)
+			return resp, nil
 		}
 
-		allSegments = append(allSegments, segments...)
+		bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusUnauthorized:
+			if !reauthorized {
+				reauthorized = true
+				c.auth.Invalidate()
+				c.logger.WarnContext(ctx, "SAP API rejected credentials, refreshing and retrying")
+				progress.FromContext(ctx).Retry(resp.StatusCode, attempt+1, 0)
+				continue
+			}
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+			c.throttle.OnThrottle(resp.StatusCode, retryAfter)
 
-		offset += c.batchSize
+			if attempt < c.maxRetries {
+				c.logger.WarnContext(ctx, "SAP API throttled request, retrying",
+					"status", resp.StatusCode,
+					"attempt", attempt+1,
+					"retry_after", retryAfter,
+					"rate", c.limiter.Rate(),
+				)
+				progress.FromContext(ctx).Retry(resp.StatusCode, attempt+1, retryAfter)
+				continue
+			}
+		}
 
-		time.Sleep(c.interval)
-	}
+		c.logger.ErrorContext(ctx, "SAP API returned error status",
+			"status", resp.StatusCode,
+			"body", string(bodyBytes),
+		)
 
-	c.logger.Info("finished fetching data from SAP API", "total_segments", len(allSegments))
+		if resp.StatusCode == http.StatusUnauthorized {
+			return nil, errUnauthorized
+		}
 
-	return allSegments, nil
+		return nil, fmt.Errorf("error response from SAP API: status=%d, body=%s",
+			resp.StatusCode, string(bodyBytes))
+	}
 }
@@ -0,0 +1,236 @@
+package sap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"go-test/internal/models"
+)
+
+// link - ссылка навигации Oracle ORDS
+type link struct {
+	Rel  string `json:"rel"`
+	Href string `json:"href"`
+}
+
+// page - страница ответа SAP API с метаданными пагинации Oracle ORDS
+type page struct {
+	Segments []*models.Segmentation
+	HasMore  *bool
+	Limit    int
+	Offset   int
+	Count    int
+	Links    []link
+}
+
+// NextLink возвращает ссылку на следующую страницу или пустую строку
+func (p *page) NextLink() string {
+	for _, l := range p.Links {
+		if l.Rel == "next" {
+			return l.Href
+		}
+	}
+
+	return ""
+}
+
+// Last сообщает, что после этой страницы данных нет. Приоритет у hasMore,
+// затем у ссылок навигации; без метаданных концом считается пустая страница.
+func (p *page) Last() bool {
+	switch {
+	case len(p.Segments) == 0:
+		return true
+	case p.HasMore != nil:
+		return !*p.HasMore
+	case len(p.Links) > 0:
+		return p.NextLink() == ""
+	default:
+		return false
+	}
+}
+
+// DecodeError описывает ошибку разбора ответа SAP API
+type DecodeError struct {
+	// Item - номер записи на странице, -1 для ошибок вне items
+	Item   int
+	Field  string
+	Reason string
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	switch {
+	case e.Item >= 0 && e.Field != "":
+		return fmt.Sprintf("invalid SAP response: item %d: field %q: %s", e.Item, e.Field, e.Reason)
+	case e.Item >= 0:
+		return fmt.Sprintf("invalid SAP response: item %d: %s", e.Item, e.Reason)
+	case e.Field != "":
+		return fmt.Sprintf("invalid SAP response: field %q: %s", e.Field, e.Reason)
+	default:
+		return "invalid SAP response: " + e.Reason
+	}
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// item - запись в ответе SAP. Указатели позволяют отличить отсутствующие
+// поля от нулевых значений.
+type item struct {
+	AddressSapID *string    `json:"address_sap_id"`
+	AdrSegment   *string    `json:"adr_segment"`
+	SegmentID    *int64     `json:"segment_id"`
+	ChangedAt    *time.Time `json:"changed_at"`
+}
+
+// decodePage потоково разбирает ответ SAP API. Поддерживаются объект ORDS
+// с items и метаданными, массив записей и пустое тело. Неизвестные поля
+// пропускаются.
+func decodePage(r io.Reader) (*page, error) {
+	dec := json.NewDecoder(r)
+
+	tok, err := dec.Token()
+	if errors.Is(err, io.EOF) {
+		return &page{}, nil
+	}
+	if err != nil {
+		return nil, &DecodeError{Item: -1, Reason: "malformed JSON: " + err.Error(), Err: err}
+	}
+
+	p := &page{}
+
+	switch tok {
+	case json.Delim('['):
+		if p.Segments, err = decodeItems(dec); err != nil {
+			return nil, err
+		}
+	case json.Delim('{'):
+		if err := decodeObject(dec, p); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, &DecodeError{Item: -1, Reason: fmt.Sprintf("expected object or array, got %v", tok)}
+	}
+
+	return p, nil
+}
+
+// decodeObject разбирает поля объекта ORDS после открывающей скобки
+func decodeObject(dec *json.Decoder, p *page) error {
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return &DecodeError{Item: -1, Reason: "malformed JSON: " + err.Error(), Err: err}
+		}
+
+		key, _ := tok.(string)
+
+		var target any
+		switch key {
+		case "items":
+			if err := expectDelim(dec, '[', "items"); err != nil {
+				return err
+			}
+			if p.Segments, err = decodeItems(dec); err != nil {
+				return err
+			}
+			continue
+		case "hasMore":
+			target = &p.HasMore
+		case "limit":
+			target = &p.Limit
+		case "offset":
+			target = &p.Offset
+		case "count":
+			target = &p.Count
+		case "links":
+			target = &p.Links
+		default:
+			target = &json.RawMessage{}
+		}
+
+		if err := dec.Decode(target); err != nil {
+			return fieldError(-1, key, err)
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return &DecodeError{Item: -1, Reason: "malformed JSON: " + err.Error(), Err: err}
+	}
+
+	return nil
+}
+
+// decodeItems разбирает записи по одной после открывающей скобки массива
+func decodeItems(dec *json.Decoder) ([]*models.Segmentation, error) {
+	var segments []*models.Segmentation
+
+	for i := 0; dec.More(); i++ {
+		var it item
+		if err := dec.Decode(&it); err != nil {
+			return nil, fieldError(i, "", err)
+		}
+
+		switch {
+		case it.AddressSapID == nil:
+			return nil, &DecodeError{Item: i, Field: "address_sap_id", Reason: "missing required field"}
+		case it.AdrSegment == nil:
+			return nil, &DecodeError{Item: i, Field: "adr_segment", Reason: "missing required field"}
+		case it.SegmentID == nil:
+			return nil, &DecodeError{Item: i, Field: "segment_id", Reason: "missing required field"}
+		}
+
+		segments = append(segments, &models.Segmentation{
+			AddressSapID: *it.AddressSapID,
+			AdrSegment:   *it.AdrSegment,
+			SegmentID:    *it.SegmentID,
+			ChangedAt:    it.ChangedAt,
+		})
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return nil, &DecodeError{Item: -1, Field: "items", Reason: "malformed JSON: " + err.Error(), Err: err}
+	}
+
+	return segments, nil
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim, field string) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return &DecodeError{Item: -1, Field: field, Reason: "malformed JSON: " + err.Error(), Err: err}
+	}
+
+	if tok != delim {
+		return &DecodeError{Item: -1, Field: field, Reason: fmt.Sprintf("expected %v, got %v", delim, tok)}
+	}
+
+	return nil
+}
+
+// fieldError превращает ошибку encoding/json в DecodeError с именем поля
+func fieldError(itemIndex int, field string, err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		if typeErr.Field != "" {
+			field = typeErr.Field
+		}
+		return &DecodeError{
+			Item:   itemIndex,
+			Field:  field,
+			Reason: fmt.Sprintf("expected %s, got %s", typeErr.Type, typeErr.Value),
+			Err:    err,
+		}
+	}
+
+	var timeErr *time.ParseError
+	if errors.As(err, &timeErr) {
+		return &DecodeError{Item: itemIndex, Field: "changed_at", Reason: "invalid timestamp: " + timeErr.Value, Err: err}
+	}
+
+	return &DecodeError{Item: itemIndex, Field: field, Reason: "malformed JSON: " + err.Error(), Err: err}
+}
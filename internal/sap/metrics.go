@@ -0,0 +1,14 @@
+package sap
+
+import "go-test/internal/metrics"
+
+var (
+	requestsTotal = metrics.NewCounterVec("sap_requests_total",
+		"Количество запросов к SAP API по HTTP-статусу", "status")
+	throttledTotal = metrics.NewCounter("sap_throttled_total",
+		"Количество ответов SAP API 429/503, снизивших частоту запросов")
+	requestRateGauge = metrics.NewGauge("sap_request_rate",
+		"Текущая допустимая частота запросов к SAP API в секунду")
+	breakerState = metrics.NewGaugeVec("sap_circuit_breaker_state",
+		"Состояние автоматического выключателя SAP API (1 - текущее)", "state")
+)
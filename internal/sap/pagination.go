@@ -0,0 +1,158 @@
+package sap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go-test/internal/models"
+	"go-test/internal/progress"
+)
+
+// Pagination - способ обхода страниц SAP API
+type Pagination string
+
+const (
+	// PaginationOffset - p_limit/p_offset, страницы загружаются параллельно
+	PaginationOffset Pagination = "offset"
+	// PaginationLink - переход по ссылке next из ответа Oracle ORDS
+	PaginationLink Pagination = "link"
+	// PaginationKeyset - запрос записей после последнего полученного address_sap_id
+	PaginationKeyset Pagination = "keyset"
+)
+
+// errNoNextLink возвращается, если SAP сообщил о следующей странице, но не передал ссылку на нее
+var errNoNextLink = errors.New("SAP API reported more data but returned no next link")
+
+// buildURL добавляет к запросу фильтр по дате изменения и формирует URL
+func (c *Client) buildURL(query url.Values, since time.Time) string {
+	if !since.IsZero() {
+		query.Set(c.changedSinceParam, since.UTC().Format(time.RFC3339))
+	}
+
+	return c.baseURL + "?" + query.Encode()
+}
+
+// offsetURL формирует URL страницы по смещению
+func (c *Client) offsetURL(limit, offset int, since time.Time) string {
+	query := url.Values{}
+	query.Set("p_limit", strconv.Itoa(limit))
+	query.Set("p_offset", strconv.Itoa(offset))
+
+	return c.buildURL(query, since)
+}
+
+// keysetURL формирует URL страницы записей, следующих за after
+func (c *Client) keysetURL(limit int, after string, since time.Time) string {
+	query := url.Values{}
+	query.Set("p_limit", strconv.Itoa(limit))
+	if after != "" {
+		query.Set(c.keysetParam, after)
+	}
+
+	return c.buildURL(query, since)
+}
+
+// fetchSequential загружает страницы по одной, получая адрес следующей
+// страницы из предыдущей: по ссылке next или по последнему address_sap_id
+func (c *Client) fetchSequential(ctx context.Context, since time.Time) ([]*models.Segmentation, error) {
+	var allSegments []*models.Segmentation
+
+	batchSize := int(c.batchSize.Load())
+
+	var reqURL string
+	if c.pagination == PaginationKeyset {
+		reqURL = c.keysetURL(batchSize, "", since)
+	} else {
+		reqURL = c.offsetURL(batchSize, 0, since)
+	}
+
+	visited := make(map[string]struct{})
+
+	for {
+		if _, ok := visited[reqURL]; ok {
+			return nil, fmt.Errorf("SAP API pagination loop detected at %s", reqURL)
+		}
+		visited[reqURL] = struct{}{}
+
+		p, err := c.fetchPage(ctx, reqURL)
+		if err != nil {
+			return nil, err
+		}
+
+		allSegments = append(allSegments, p.Segments...)
+		progress.FromContext(ctx).PageFetched(len(p.Segments))
+
+		if p.Last() {
+			return allSegments, nil
+		}
+
+		if reqURL, err = c.nextURL(p, batchSize, since); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// nextURL возвращает адрес страницы, следующей за p
+func (c *Client) nextURL(p *page, batchSize int, since time.Time) (string, error) {
+	if c.pagination == PaginationKeyset {
+		last := p.Segments[len(p.Segments)-1]
+		return c.keysetURL(batchSize, last.AddressSapID, since), nil
+	}
+
+	next := p.NextLink()
+	if next == "" {
+		return "", errNoNextLink
+	}
+
+	return c.resolveLink(next)
+}
+
+// resolveLink приводит ссылку ORDS к абсолютному URL. Ссылки на другой хост
+// отклоняются, чтобы не отправить туда учетные данные.
+func (c *Client) resolveLink(href string) (string, error) {
+	base, err := url.Parse(c.baseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid SAP API URL: %w", err)
+	}
+
+	ref, err := url.Parse(href)
+	if err != nil {
+		return "", fmt.Errorf("invalid next link %q: %w", href, err)
+	}
+
+	resolved := base.ResolveReference(ref)
+	if resolved.Host != base.Host {
+		return "", fmt.Errorf("next link %q points to a different host", href)
+	}
+
+	return resolved.String(), nil
+}
+
+// dedupe убирает полностью совпадающие повторы записей, которые появляются
+// при сдвиге данных во время постраничной загрузки. Повторы ID с разными
+// значениями сохраняются, чтобы валидация отправила их в карантин.
+func dedupe(segments []*models.Segmentation) ([]*models.Segmentation, int) {
+	type key struct {
+		addressSapID string
+		adrSegment   string
+		segmentID    int64
+	}
+
+	seen := make(map[key]struct{}, len(segments))
+	result := segments[:0]
+
+	for _, s := range segments {
+		k := key{s.AddressSapID, s.AdrSegment, s.SegmentID}
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		result = append(result, s)
+	}
+
+	return result, len(segments) - len(result)
+}
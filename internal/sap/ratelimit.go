@@ -0,0 +1,90 @@
+package sap
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter ограничивает частоту запросов к SAP API по принципу
+// token bucket. Общий для всех параллельных загрузчиков страниц.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration // интервал между запросами; 0 - без ограничения
+	burst    int
+	next     time.Time // момент, когда может быть выполнен следующий запрос
+}
+
+// newRateLimiter создает лимитер на rate запросов в секунду.
+// Неположительный rate отключает ограничение.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	l := &rateLimiter{burst: max(burst, 1)}
+	l.SetRate(rate)
+
+	return l
+}
+
+// SetRate меняет допустимую частоту запросов
+func (l *rateLimiter) SetRate(rate float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if rate <= 0 {
+		l.interval = 0
+		return
+	}
+
+	l.interval = time.Duration(float64(time.Second) / rate)
+}
+
+// Rate возвращает текущую частоту запросов в секунду, 0 - без ограничения
+func (l *rateLimiter) Rate() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.interval == 0 {
+		return 0
+	}
+
+	return float64(time.Second) / float64(l.interval)
+}
+
+// Pause откладывает следующие запросы не менее чем на d
+func (l *rateLimiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if resume := time.Now().Add(d); l.next.Before(resume) {
+		l.next = resume
+	}
+}
+
+// Wait блокируется, пока запрос не будет разрешен, или до отмены ctx
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+
+	// Неиспользованные запросы накапливаются, но не более burst
+	earliest := now.Add(-time.Duration(l.burst-1) * l.interval)
+	if l.next.Before(earliest) {
+		l.next = earliest
+	}
+
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,525 @@
+// Package sapmock содержит имитацию SAP API сегментации для локальной
+// разработки и интеграционных тестов.
+package sapmock
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-test/internal/models"
+)
+
+// SegmentationPath - путь, по которому отвечает имитация, как в реальном SAP API
+const SegmentationPath = "/ords/bsm/segmentation/get_segmentation"
+
+// FaultPath - служебный путь для управления сбоями во время работы
+const FaultPath = "/mock/fault"
+
+// TokenPath - имитация сервера токенов OAuth2 (client credentials)
+const TokenPath = "/oauth/token"
+
+//go:embed fixtures/segmentation.json
+var defaultFixture []byte
+
+// FaultKind описывает тип сбоя, который имитация вернет вместо страницы
+type FaultKind string
+
+const (
+	FaultNone        FaultKind = ""
+	FaultServerError FaultKind = "server_error"
+	FaultMalformed   FaultKind = "malformed_json"
+	FaultEmptyArray  FaultKind = "empty_array"
+	FaultEmptyObject FaultKind = "empty_object"
+)
+
+// Fault задает сбой, применяемый к запросам сегментации
+type Fault struct {
+	Kind FaultKind `json:"kind"`
+	// Status - HTTP-статус для FaultServerError, по умолчанию 500
+	Status int `json:"status,omitempty"`
+	// RetryAfter - значение заголовка Retry-After в секундах для FaultServerError
+	RetryAfter int `json:"retry_after,omitempty"`
+	// Latency - дополнительная задержка перед ответом
+	Latency time.Duration `json:"-"`
+	// Count - сколько запросов затронет сбой; 0 - до сброса
+	Count int `json:"count,omitempty"`
+}
+
+// Options содержит настройки имитации
+type Options struct {
+	// Username и Password включают Basic-аутентификацию, если заданы
+	Username string
+	Password string
+	// BearerToken включает аутентификацию статическим токеном
+	BearerToken string
+	// ClientID и ClientSecret включают выдачу токенов OAuth2 на TokenPath
+	ClientID     string
+	ClientSecret string
+	// TokenTTL - срок действия выдаваемых токенов, по умолчанию 1 час
+	TokenTTL time.Duration
+	// Latency - задержка перед каждым ответом
+	Latency time.Duration
+	// ChangedSinceParam - параметр фильтра по дате изменения, по умолчанию p_changed_since
+	ChangedSinceParam string
+	// KeysetParam - параметр keyset-пагинации, по умолчанию p_after
+	KeysetParam string
+	// Dataset - набор данных; если пуст, используется встроенный фикстур
+	Dataset []models.Segmentation
+	Logger  *slog.Logger
+}
+
+// Record - запись сегментации в том виде, в котором ее отдает SAP
+type Record struct {
+	AddressSapID string     `json:"address_sap_id"`
+	AdrSegment   string     `json:"adr_segment"`
+	SegmentID    int64      `json:"segment_id"`
+	ChangedAt    *time.Time `json:"changed_at,omitempty"`
+}
+
+// Link - ссылка навигации Oracle ORDS
+type Link struct {
+	Rel  string `json:"rel"`
+	Href string `json:"href"`
+}
+
+// Page - ответ страницы в формате Oracle ORDS
+type Page struct {
+	Items   []Record `json:"items"`
+	HasMore bool     `json:"hasMore"`
+	Limit   int      `json:"limit"`
+	Offset  int      `json:"offset"`
+	Count   int      `json:"count"`
+	Links   []Link   `json:"links"`
+}
+
+// Server имитирует SAP API: отдает страницы по p_limit/p_offset или после
+// указанного address_sap_id и умеет возвращать сбои по запросу. Записи
+// отдаются в порядке address_sap_id.
+type Server struct {
+	opts    Options
+	dataset []models.Segmentation
+	logger  *slog.Logger
+
+	mu     sync.Mutex
+	fault  Fault
+	tokens map[string]time.Time
+}
+
+// NewServer создает имитацию SAP API
+func NewServer(opts Options) (*Server, error) {
+	dataset := opts.Dataset
+	if len(dataset) == 0 {
+		var err error
+		if dataset, err = ParseDataset(defaultFixture); err != nil {
+			return nil, fmt.Errorf("failed to parse default fixture: %w", err)
+		}
+	}
+
+	if opts.ChangedSinceParam == "" {
+		opts.ChangedSinceParam = "p_changed_since"
+	}
+	if opts.KeysetParam == "" {
+		opts.KeysetParam = "p_after"
+	}
+	if opts.TokenTTL <= 0 {
+		opts.TokenTTL = time.Hour
+	}
+
+	dataset = append([]models.Segmentation(nil), dataset...)
+	sort.SliceStable(dataset, func(i, j int) bool {
+		return dataset[i].AddressSapID < dataset[j].AddressSapID
+	})
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
+	}
+
+	return &Server{
+		opts:    opts,
+		dataset: dataset,
+		logger:  logger,
+		tokens:  make(map[string]time.Time),
+	}, nil
+}
+
+// NewTestServer запускает имитацию на httptest.Server. URL для клиента
+// SAP - srv.URL + SegmentationPath.
+func NewTestServer(opts Options) (*httptest.Server, *Server, error) {
+	s, err := NewServer(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return httptest.NewServer(s), s, nil
+}
+
+// LoadDataset читает набор данных из JSON-файла
+func LoadDataset(path string) ([]models.Segmentation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+
+	return ParseDataset(data)
+}
+
+// ParseDataset разбирает JSON-массив записей сегментации
+func ParseDataset(data []byte) ([]models.Segmentation, error) {
+	var dataset []models.Segmentation
+	if err := json.Unmarshal(data, &dataset); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture: %w", err)
+	}
+
+	return dataset, nil
+}
+
+// SetFault включает сбой для последующих запросов
+func (s *Server) SetFault(fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fault = fault
+}
+
+// ResetFault отключает сбой
+func (s *Server) ResetFault() {
+	s.SetFault(Fault{})
+}
+
+// RevokeTokens делает недействительными все выданные токены OAuth2
+func (s *Server) RevokeTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens = make(map[string]time.Time)
+}
+
+// authorized проверяет учетные данные запроса. Без настроенной
+// аутентификации пропускаются все запросы.
+func (s *Server) authorized(r *http.Request) bool {
+	basic := s.opts.Username != "" || s.opts.Password != ""
+	if !basic && s.opts.BearerToken == "" && s.opts.ClientID == "" {
+		return true
+	}
+
+	if user, pass, ok := r.BasicAuth(); ok {
+		return basic && user == s.opts.Username && pass == s.opts.Password
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+
+	if s.opts.BearerToken != "" && token == s.opts.BearerToken {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiry, ok := s.tokens[token]
+	return ok && time.Now().Before(expiry)
+}
+
+// serveToken выдает токены по OAuth2 client credentials. Учетные данные
+// клиента принимаются в Basic-заголовке или в теле формы.
+func (s *Server) serveToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.opts.ClientID == "" {
+		http.Error(w, "oauth2 is not configured", http.StatusNotFound)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	if r.PostForm.Get("grant_type") != "client_credentials" {
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID = r.PostForm.Get("client_id")
+		clientSecret = r.PostForm.Get("client_secret")
+	}
+
+	if clientID != s.opts.ClientID || clientSecret != s.opts.ClientSecret {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+
+	raw := make([]byte, 16)
+	_, _ = rand.Read(raw)
+	token := hex.EncodeToString(raw)
+
+	s.mu.Lock()
+	s.tokens[token] = time.Now().Add(s.opts.TokenTTL)
+	s.mu.Unlock()
+
+	s.logger.Info("mock SAP token issued", "client_id", clientID, "ttl", s.opts.TokenTTL)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int64(s.opts.TokenTTL / time.Second),
+	})
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": code})
+}
+
+// takeFault возвращает текущий сбой и уменьшает его счетчик
+func (s *Server) takeFault() Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fault := s.fault
+	if fault.Count > 0 {
+		s.fault.Count--
+		if s.fault.Count == 0 {
+			s.fault = Fault{}
+		}
+	}
+
+	return fault
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case SegmentationPath:
+		s.serveSegmentation(w, r)
+	case FaultPath:
+		s.serveFault(w, r)
+	case TokenPath:
+		s.serveToken(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveSegmentation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="sap"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limit, err := queryInt(r, "p_limit", 25)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	offset, err := queryInt(r, "p_offset", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var since time.Time
+	if raw := r.URL.Query().Get(s.opts.ChangedSinceParam); raw != "" {
+		if since, err = time.Parse(time.RFC3339, raw); err != nil {
+			http.Error(w, "invalid "+s.opts.ChangedSinceParam, http.StatusBadRequest)
+			return
+		}
+	}
+
+	fault := s.takeFault()
+	time.Sleep(s.opts.Latency + fault.Latency)
+
+	s.logger.Info("mock SAP request",
+		"limit", limit,
+		"offset", offset,
+		"changed_since", since,
+		"fault", fault.Kind,
+	)
+
+	switch fault.Kind {
+	case FaultServerError:
+		status := fault.Status
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		if fault.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(fault.RetryAfter))
+		}
+		http.Error(w, "injected failure", status)
+		return
+	case FaultMalformed:
+		writeRaw(w, `{"items": [{"address_sap_id": "SAP-`)
+		return
+	case FaultEmptyArray:
+		writeRaw(w, "[]")
+		return
+	case FaultEmptyObject:
+		writeRaw(w, "{}")
+		return
+	}
+
+	items := s.filter(since)
+
+	// В keyset-режиме смещение отсчитывается от первой записи после after
+	if after := r.URL.Query().Get(s.opts.KeysetParam); after != "" {
+		start := sort.Search(len(items), func(i int) bool {
+			return items[i].AddressSapID > after
+		})
+		items = items[start:]
+	}
+
+	page := Page{
+		Items:  make([]Record, 0, limit),
+		Limit:  limit,
+		Offset: offset,
+	}
+	if offset < len(items) {
+		end := min(offset+limit, len(items))
+		for _, item := range items[offset:end] {
+			page.Items = append(page.Items, Record{
+				AddressSapID: item.AddressSapID,
+				AdrSegment:   item.AdrSegment,
+				SegmentID:    item.SegmentID,
+				ChangedAt:    item.ChangedAt,
+			})
+		}
+		page.HasMore = end < len(items)
+	}
+	page.Count = len(page.Items)
+	page.Links = pageLinks(r, limit, offset, page.HasMore)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		s.logger.Error("failed to encode mock page", "error", err.Error())
+	}
+}
+
+// filter возвращает записи, измененные начиная с since
+func (s *Server) filter(since time.Time) []models.Segmentation {
+	if since.IsZero() {
+		return s.dataset
+	}
+
+	items := make([]models.Segmentation, 0, len(s.dataset))
+	for _, item := range s.dataset {
+		if item.ChangedAt != nil && !item.ChangedAt.Before(since) {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// serveFault позволяет включать (PUT) и сбрасывать (DELETE) сбои по HTTP.
+// Пример тела PUT: {"kind": "server_error", "status": 503, "latency": "2s", "count": 3}
+func (s *Server) serveFault(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPut:
+		var req struct {
+			Fault
+			Latency string `json:"latency"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid fault: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		fault := req.Fault
+		if req.Latency != "" {
+			latency, err := time.ParseDuration(req.Latency)
+			if err != nil {
+				http.Error(w, "invalid latency: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			fault.Latency = latency
+		}
+
+		s.SetFault(fault)
+		s.logger.Info("mock SAP fault enabled", "kind", fault.Kind, "count", fault.Count)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		s.ResetFault()
+		s.logger.Info("mock SAP fault reset")
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// pageLinks формирует ссылки self и next, как это делает ORDS
+func pageLinks(r *http.Request, limit, offset int, hasMore bool) []Link {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	link := func(offset int) string {
+		query := url.Values{}
+		for key, values := range r.URL.Query() {
+			query[key] = values
+		}
+		query.Set("p_limit", strconv.Itoa(limit))
+		query.Set("p_offset", strconv.Itoa(offset))
+
+		return scheme + "://" + r.Host + r.URL.Path + "?" + query.Encode()
+	}
+
+	links := []Link{{Rel: "self", Href: link(offset)}}
+	if hasMore {
+		links = append(links, Link{Rel: "next", Href: link(offset + limit)})
+	}
+
+	return links
+}
+
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid %s", name)
+	}
+
+	return value, nil
+}
+
+func writeRaw(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(body))
+}
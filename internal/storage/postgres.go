@@ -12,7 +12,7 @@ import (
 
 func NewPostgresDB(cfg *config.Config, logger *slog.Logger) (*sqlx.DB, error) {
 	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		cfg.DB.Host, cfg.DB.Port, cfg.DB.User, cfg.DB.Password, cfg.DB.Name)
+		cfg.DB.Host, cfg.DB.Port, cfg.DB.User, cfg.DB.Password.Value(), cfg.DB.Name)
 
 	logger.Info("connecting to database",
 		"host", cfg.DB.Host,
@@ -0,0 +1,70 @@
+package validation
+
+import (
+	"fmt"
+
+	"go-test/internal/models"
+)
+
+// CheckSegments сверяет записи со справочником сегментов known. Пара
+// segment_id и adr_segment должна совпадать с записью справочника, а сегмент -
+// быть активным. Сегменты, которых нет в справочнике, в режиме register
+// возвращаются в register для регистрации (первая пара в импорте задает код
+// сегмента), в режиме reject записи с ними отклоняются.
+func (v *Validator) CheckSegments(
+	segments []*models.Segmentation,
+	known []*models.Segment,
+) ([]*models.Segmentation, []*models.Segment, []models.Reject) {
+	byID := make(map[int64]*models.Segment, len(known))
+	byCode := make(map[string]int64, len(known))
+	for _, segment := range known {
+		byID[segment.ID] = segment
+		byCode[segment.Code] = segment.ID
+	}
+
+	valid := make([]*models.Segmentation, 0, len(segments))
+	var register []*models.Segment
+	var rejects []models.Reject
+
+	for _, segment := range segments {
+		if registered, ok := byID[segment.SegmentID]; ok {
+			switch {
+			case registered.Code != segment.AdrSegment:
+				rejects = append(rejects, newReject(segment, ReasonSegmentCodeMismatch,
+					fmt.Sprintf("adr_segment %q does not match code %q of segment %d",
+						segment.AdrSegment, registered.Code, registered.ID)))
+			case !registered.Active:
+				rejects = append(rejects, newReject(segment, ReasonInactiveSegment,
+					fmt.Sprintf("segment %d (%q) is inactive", registered.ID, registered.Code)))
+			default:
+				valid = append(valid, segment)
+			}
+			continue
+		}
+
+		if id, ok := byCode[segment.AdrSegment]; ok {
+			rejects = append(rejects, newReject(segment, ReasonSegmentCodeMismatch,
+				fmt.Sprintf("adr_segment %q is registered for segment %d", segment.AdrSegment, id)))
+			continue
+		}
+
+		if v.unknownSegments == UnknownSegmentsReject {
+			rejects = append(rejects, newReject(segment, ReasonUnknownSegment,
+				fmt.Sprintf("segment %d (%q) is not registered", segment.SegmentID, segment.AdrSegment)))
+			continue
+		}
+
+		registered := &models.Segment{
+			ID:     segment.SegmentID,
+			Code:   segment.AdrSegment,
+			Name:   segment.AdrSegment,
+			Active: true,
+		}
+		byID[registered.ID] = registered
+		byCode[registered.Code] = registered.ID
+		register = append(register, registered)
+		valid = append(valid, segment)
+	}
+
+	return valid, register, rejects
+}
@@ -0,0 +1,131 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"go-test/internal/models"
+	"go-test/pkg/config"
+)
+
+// Коды причин отклонения записей
+const (
+	ReasonEmptyAddressSapID     = "empty_address_sap_id"
+	ReasonAddressSapIDTooLong   = "address_sap_id_too_long"
+	ReasonEmptyAdrSegment       = "empty_adr_segment"
+	ReasonAdrSegmentTooLong     = "adr_segment_too_long"
+	ReasonUnknownAdrSegment     = "unknown_adr_segment"
+	ReasonDuplicateAddressSapID = "duplicate_address_sap_id"
+	ReasonUnknownSegment        = "unknown_segment"
+	ReasonSegmentCodeMismatch   = "segment_code_mismatch"
+	ReasonInactiveSegment       = "inactive_segment"
+)
+
+// Режимы обработки сегментов, отсутствующих в справочнике
+const (
+	UnknownSegmentsRegister = "register"
+	UnknownSegmentsReject   = "reject"
+)
+
+// Validator проверяет записи из SAP перед сохранением в базу данных
+type Validator struct {
+	maxAddressSapIDLength int
+	maxAdrSegmentLength   int
+	allowedSegments       map[string]struct{}
+	rejectDuplicates      bool
+	unknownSegments       string
+}
+
+// NewValidator создает валидатор с правилами из конфигурации
+func NewValidator(cfg *config.Config) *Validator {
+	v := &Validator{
+		maxAddressSapIDLength: cfg.Validation.MaxAddressSapIDLength,
+		maxAdrSegmentLength:   cfg.Validation.MaxAdrSegmentLength,
+		rejectDuplicates:      cfg.Validation.RejectDuplicates,
+		unknownSegments:       cfg.Validation.UnknownSegments,
+	}
+
+	if len(cfg.Validation.AllowedSegments) > 0 {
+		v.allowedSegments = make(map[string]struct{}, len(cfg.Validation.AllowedSegments))
+		for _, segment := range cfg.Validation.AllowedSegments {
+			v.allowedSegments[strings.TrimSpace(segment)] = struct{}{}
+		}
+	}
+
+	return v
+}
+
+// Validate разделяет записи на корректные и отклоненные. Повторы
+// address_sap_id разрешаются только здесь: сохраняется первая корректная
+// запись, а последующие отклоняются с причиной duplicate_address_sap_id
+// (VALIDATION_REJECT_DUPLICATES) или отбрасываются без записи в карантин.
+// Возвращаемые корректные записи не содержат повторов address_sap_id.
+func (v *Validator) Validate(segments []*models.Segmentation) ([]*models.Segmentation, []models.Reject) {
+	valid := make([]*models.Segmentation, 0, len(segments))
+	var rejects []models.Reject
+
+	seen := make(map[string]struct{}, len(segments))
+
+	for _, segment := range segments {
+		reason, details := v.check(segment)
+
+		if reason == "" {
+			if _, ok := seen[segment.AddressSapID]; ok {
+				if !v.rejectDuplicates {
+					continue
+				}
+				reason = ReasonDuplicateAddressSapID
+				details = "address_sap_id already present in this import"
+			}
+		}
+
+		if reason != "" {
+			rejects = append(rejects, newReject(segment, reason, details))
+			continue
+		}
+
+		seen[segment.AddressSapID] = struct{}{}
+		valid = append(valid, segment)
+	}
+
+	return valid, rejects
+}
+
+// check проверяет отдельную запись. Длина считается в символах,
+// как и у колонок VARCHAR в Postgres.
+func (v *Validator) check(segment *models.Segmentation) (string, string) {
+	idLength := utf8.RuneCountInString(segment.AddressSapID)
+	segmentLength := utf8.RuneCountInString(segment.AdrSegment)
+
+	switch {
+	case strings.TrimSpace(segment.AddressSapID) == "":
+		return ReasonEmptyAddressSapID, "address_sap_id is empty"
+	case v.maxAddressSapIDLength > 0 && idLength > v.maxAddressSapIDLength:
+		return ReasonAddressSapIDTooLong, fmt.Sprintf("address_sap_id length %d exceeds %d",
+			idLength, v.maxAddressSapIDLength)
+	case strings.TrimSpace(segment.AdrSegment) == "":
+		return ReasonEmptyAdrSegment, "adr_segment is empty"
+	case v.maxAdrSegmentLength > 0 && segmentLength > v.maxAdrSegmentLength:
+		return ReasonAdrSegmentTooLong, fmt.Sprintf("adr_segment length %d exceeds %d",
+			segmentLength, v.maxAdrSegmentLength)
+	}
+
+	if v.allowedSegments != nil {
+		if _, ok := v.allowedSegments[segment.AdrSegment]; !ok {
+			return ReasonUnknownAdrSegment, fmt.Sprintf("adr_segment %q is not in the allowed list", segment.AdrSegment)
+		}
+	}
+
+	return "", ""
+}
+
+func newReject(segment *models.Segmentation, reason, details string) models.Reject {
+	return models.Reject{
+		AddressSapID: segment.AddressSapID,
+		AdrSegment:   segment.AdrSegment,
+		SegmentID:    segment.SegmentID,
+		Reason:       reason,
+		Details:      details,
+	}
+}
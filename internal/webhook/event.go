@@ -0,0 +1,109 @@
+// Package webhook отправляет уведомления о событиях импорта на адреса
+// получателей: подписанные HMAC JSON-запросы с повторами и журналом доставки.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-test/internal/models"
+)
+
+// Типы событий
+const (
+	EventImportSucceeded = "import.succeeded"
+	EventImportFailed    = "import.failed"
+	EventSegmentChanged  = "segment.changed"
+)
+
+// Заголовки запроса с событием
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderID        = "X-Webhook-ID"
+	HeaderAttempt   = "X-Webhook-Attempt"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Event - тело запроса к получателю
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// SegmentChangedData - данные события segment.changed. Изменения одного
+// импорта могут быть разбиты на несколько событий.
+type SegmentChangedData struct {
+	ImportID int64                  `json:"import_id"`
+	Changes  []models.SegmentChange `json:"changes"`
+}
+
+func newEvent(eventType string, data any) *Event {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return &Event{
+		ID:        hex.EncodeToString(b),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+}
+
+// Sign возвращает значение заголовка X-Webhook-Signature для тела body:
+// t=<unix-время>,v1=<hex HMAC-SHA256 от "<t>.<body>">
+func Sign(secret string, body []byte, t time.Time) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+
+	return "t=" + ts + ",v1=" + signature(secret, ts, body)
+}
+
+// Verify проверяет заголовок X-Webhook-Signature. Подпись старше tolerance
+// отклоняется, чтобы запрос нельзя было повторить; tolerance = 0 отключает
+// проверку времени.
+func Verify(secret string, body []byte, header string, tolerance time.Duration, now time.Time) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			sig = value
+		}
+	}
+	if ts == "" || sig == "" {
+		return errors.New("malformed signature header")
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid signature timestamp: %w", err)
+	}
+	if tolerance > 0 && now.Sub(time.Unix(unix, 0)).Abs() > tolerance {
+		return errors.New("signature timestamp is outside the tolerance")
+	}
+
+	if !hmac.Equal([]byte(sig), []byte(signature(secret, ts, body))) {
+		return errors.New("signature mismatch")
+	}
+
+	return nil
+}
+
+func signature(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
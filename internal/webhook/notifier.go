@@ -0,0 +1,290 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-test/internal/metrics"
+	"go-test/internal/models"
+	"go-test/internal/repository"
+	"go-test/pkg/config"
+)
+
+// maxRetryBackoff ограничивает паузу между повторами
+const maxRetryBackoff = 5 * time.Minute
+
+// saveDeliveryTimeout ограничивает запись попытки в журнал доставки
+const saveDeliveryTimeout = 5 * time.Second
+
+// maxResponseBodySize ограничивает часть ответа получателя, попадающую в журнал
+const maxResponseBodySize = 1024
+
+var deliveriesTotal = metrics.NewCounterVec("webhook_deliveries_total",
+	"Попытки доставки уведомлений по результату: succeeded, retried, failed, dropped", "result")
+
+// Notifier доставляет события получателям из WEBHOOK_URLS. У каждого
+// получателя своя очередь и горутина: события доставляются по порядку,
+// а недоступный получатель не задерживает остальных. Каждая попытка
+// записывается в журнал доставки.
+type Notifier struct {
+	logger     *slog.Logger
+	deliveries *repository.WebhookRepository
+	httpClient *http.Client
+
+	secret          string
+	events          []string
+	maxRetries      int
+	retryBackoff    time.Duration
+	changesPerEvent int
+
+	endpoints []*endpoint
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// endpoint - получатель со своей очередью
+type endpoint struct {
+	url   string
+	queue chan *delivery
+}
+
+// delivery - событие, сериализованное в момент отправки в очередь
+type delivery struct {
+	eventID   string
+	eventType string
+	body      []byte
+}
+
+// NewNotifier создает Notifier и запускает доставку. deliveries может быть
+// nil - тогда журнал доставки не ведется. Без WEBHOOK_URLS события
+// отбрасываются.
+func NewNotifier(cfg *config.Config, logger *slog.Logger, deliveries *repository.WebhookRepository) *Notifier {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	n := &Notifier{
+		logger:          logger,
+		deliveries:      deliveries,
+		httpClient:      &http.Client{Timeout: cfg.Webhook.Timeout},
+		secret:          cfg.Webhook.Secret.Value(),
+		events:          cfg.Webhook.Events,
+		maxRetries:      cfg.Webhook.MaxRetries,
+		retryBackoff:    cfg.Webhook.RetryBackoff,
+		changesPerEvent: max(cfg.Webhook.ChangesPerEvent, 1),
+		ctx:             ctx,
+		cancel:          cancel,
+	}
+
+	for _, url := range cfg.Webhook.URLs {
+		ep := &endpoint{url: url, queue: make(chan *delivery, max(cfg.Webhook.QueueSize, 1))}
+		n.endpoints = append(n.endpoints, ep)
+
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			for d := range ep.queue {
+				n.deliver(ep.url, d)
+			}
+		}()
+	}
+
+	return n
+}
+
+// Notify ставит событие в очередь всех получателей. Данные сериализуются
+// сразу, поэтому их можно менять после вызова.
+func (n *Notifier) Notify(ctx context.Context, eventType string, data any) {
+	if len(n.endpoints) == 0 || (len(n.events) > 0 && !slices.Contains(n.events, eventType)) {
+		return
+	}
+
+	event := newEvent(eventType, data)
+	body, err := json.Marshal(event)
+	if err != nil {
+		n.logger.ErrorContext(ctx, "failed to encode webhook event", "event_type", eventType, "error", err.Error())
+		return
+	}
+	d := &delivery{eventID: event.ID, eventType: eventType, body: body}
+
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	if n.closed {
+		return
+	}
+
+	for _, ep := range n.endpoints {
+		select {
+		case ep.queue <- d:
+		default:
+			deliveriesTotal.With("dropped").Inc()
+			n.logger.ErrorContext(ctx, "webhook queue is full, event dropped",
+				"url", ep.url,
+				"event_id", event.ID,
+				"event_type", eventType,
+			)
+		}
+	}
+}
+
+// NotifyChanges отправляет изменения импорта событиями segment.changed
+// не более чем по WEBHOOK_CHANGES_PER_EVENT записей
+func (n *Notifier) NotifyChanges(ctx context.Context, importID int64, changes []models.SegmentChange) {
+	for chunk := range slices.Chunk(changes, n.changesPerEvent) {
+		n.Notify(ctx, EventSegmentChanged, SegmentChangedData{ImportID: importID, Changes: chunk})
+	}
+}
+
+// Close прекращает прием событий и дожидается доставки очередей. Если ctx
+// отменяется раньше, текущие попытки прерываются (и записываются в журнал
+// доставки), а оставшиеся события теряются.
+func (n *Notifier) Close(ctx context.Context) error {
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		for _, ep := range n.endpoints {
+			close(ep.queue)
+		}
+	}
+	n.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		n.cancel()
+		return nil
+	case <-ctx.Done():
+		n.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// deliver отправляет событие получателю, повторяя попытки при сетевых
+// ошибках, ответах 408, 429 и 5xx с экспоненциально растущей паузой
+func (n *Notifier) deliver(url string, d *delivery) {
+	logger := n.logger.With("url", url, "event_id", d.eventID, "event_type", d.eventType)
+
+	for attempt := 1; ; attempt++ {
+		status, err := n.send(url, d, attempt)
+		if err == nil {
+			deliveriesTotal.With("succeeded").Inc()
+			logger.DebugContext(n.ctx, "webhook delivered", "attempt", attempt)
+			return
+		}
+
+		if !retryable(status) || attempt > n.maxRetries || n.ctx.Err() != nil {
+			deliveriesTotal.With("failed").Inc()
+			logger.ErrorContext(n.ctx, "webhook delivery failed", "attempt", attempt, "error", err.Error())
+			return
+		}
+
+		deliveriesTotal.With("retried").Inc()
+		backoff := min(n.retryBackoff<<(attempt-1), maxRetryBackoff)
+		logger.WarnContext(n.ctx, "webhook delivery failed, retrying",
+			"attempt", attempt,
+			"retry_in", backoff,
+			"error", err.Error(),
+		)
+
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// send выполняет одну попытку и записывает ее в журнал. Возвращает
+// HTTP-статус (0, если ответ не получен) и ошибку, если ответ не 2xx.
+func (n *Notifier) send(url string, d *delivery, attempt int) (int, error) {
+	start := time.Now()
+	status, err := n.post(url, d, attempt)
+
+	if n.deliveries != nil {
+		record := &models.WebhookDelivery{
+			EventID:    d.eventID,
+			EventType:  d.eventType,
+			URL:        url,
+			Attempt:    attempt,
+			DurationMs: time.Since(start).Milliseconds(),
+			Succeeded:  err == nil,
+		}
+		if status != 0 {
+			record.StatusCode = &status
+		}
+		if err != nil {
+			record.Error = err.Error()
+		}
+
+		// Попытки, прерванные при остановке, тоже записываются в журнал:
+		// запись не отменяется вместе с n.ctx, но ограничена по времени
+		saveCtx, cancel := context.WithTimeout(context.WithoutCancel(n.ctx), saveDeliveryTimeout)
+		saveErr := n.deliveries.SaveDelivery(saveCtx, record)
+		cancel()
+		if saveErr != nil {
+			n.logger.ErrorContext(n.ctx, "failed to save webhook delivery", "event_id", d.eventID, "error", saveErr.Error())
+		}
+	}
+
+	return status, err
+}
+
+func (n *Notifier) post(url string, d *delivery, attempt int) (int, error) {
+	req, err := http.NewRequestWithContext(n.ctx, http.MethodPost, url, bytes.NewReader(d.body))
+	if err != nil {
+		return 0, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, d.eventType)
+	req.Header.Set(HeaderID, d.eventID)
+	req.Header.Set(HeaderAttempt, strconv.Itoa(attempt))
+	if n.secret != "" {
+		req.Header.Set(HeaderSignature, Sign(n.secret, d.body, time.Now()))
+	}
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodySize))
+	// Дочитываем ответ, чтобы соединение вернулось в пул
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("error response from webhook receiver: status=%d, body=%s",
+			resp.StatusCode, string(body))
+	}
+
+	return resp.StatusCode, nil
+}
+
+// retryable сообщает, имеет ли смысл повторить попытку с таким статусом;
+// 0 - ответ не получен
+func retryable(status int) bool {
+	return status == 0 ||
+		status == http.StatusRequestTimeout ||
+		status == http.StatusTooManyRequests ||
+		status >= http.StatusInternalServerError
+}
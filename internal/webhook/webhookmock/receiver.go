@@ -0,0 +1,136 @@
+// Package webhookmock содержит получателя уведомлений для локальной
+// проверки webhooks: он проверяет подпись, запоминает события и умеет
+// отвечать ошибкой, чтобы проверить повторы.
+package webhookmock
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-test/internal/webhook"
+)
+
+// maxBodySize ограничивает размер принимаемого события
+const maxBodySize = 10 << 20
+
+// signatureTolerance - допустимое расхождение времени подписи
+const signatureTolerance = 5 * time.Minute
+
+// Options содержит настройки получателя
+type Options struct {
+	// Secret включает проверку подписи; запрос с неверной подписью
+	// получает 401
+	Secret string
+	// FailFirst - сколько первых запросов получат ответ FailStatus
+	FailFirst int
+	// FailStatus - статус для FailFirst, по умолчанию 500
+	FailStatus int
+	// Logger для вывода полученных событий; nil - без вывода
+	Logger *slog.Logger
+}
+
+// Received - полученное событие
+type Received struct {
+	Event   webhook.Event
+	Attempt int
+}
+
+// Receiver - HTTP-обработчик, принимающий уведомления
+type Receiver struct {
+	opts Options
+
+	mu       sync.Mutex
+	requests int
+	events   []Received
+}
+
+// NewReceiver создает получателя
+func NewReceiver(opts Options) *Receiver {
+	if opts.FailStatus == 0 {
+		opts.FailStatus = http.StatusInternalServerError
+	}
+
+	return &Receiver{opts: opts}
+}
+
+// Events возвращает копию полученных событий
+func (r *Receiver) Events() []Received {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := make([]Received, len(r.events))
+	copy(events, r.events)
+
+	return events
+}
+
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	if r.opts.Secret != "" {
+		err := webhook.Verify(r.opts.Secret, body, req.Header.Get(webhook.HeaderSignature), signatureTolerance, time.Now())
+		if err != nil {
+			r.log("rejected webhook with invalid signature", "error", err.Error())
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	var event webhook.Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		http.Error(w, "invalid event", http.StatusBadRequest)
+		return
+	}
+	attempt, _ := strconv.Atoi(req.Header.Get(webhook.HeaderAttempt))
+
+	r.mu.Lock()
+	r.requests++
+	fail := r.requests <= r.opts.FailFirst
+	if !fail {
+		r.events = append(r.events, Received{Event: event, Attempt: attempt})
+	}
+	r.mu.Unlock()
+
+	if fail {
+		r.log("failing webhook as configured", "event_id", event.ID, "attempt", attempt, "status", r.opts.FailStatus)
+		http.Error(w, "simulated failure", r.opts.FailStatus)
+		return
+	}
+
+	r.log("received webhook",
+		"event_id", event.ID,
+		"event_type", event.Type,
+		"attempt", attempt,
+		"data", string(mustMarshal(event.Data)),
+	)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (r *Receiver) log(msg string, args ...any) {
+	if r.opts.Logger != nil {
+		r.opts.Logger.Info(msg, args...)
+	}
+}
+
+func mustMarshal(v any) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return []byte("null")
+	}
+
+	return b
+}
@@ -1,51 +1,265 @@
 package config
 
 import (
+	"errors"
 	"log/slog"
+	"os"
 	"time"
-
-	"github.com/kelseyhightower/envconfig"
 )
 
-// Config содержит все настройки приложения
+// Config содержит все настройки приложения. Значения собираются слоями:
+// значения по умолчанию (тег default), YAML-файл из CONFIG_FILE (тег yaml)
+// и переменные окружения (тег env), каждый следующий слой важнее.
 type Config struct {
-	Env      string        `envconfig:"ENV" default:"local"`
-	TokenTTL time.Duration `envconfig:"TOKEN_TTL" default:"1h"`
-
-	DB struct {
-		Host     string `envconfig:"DB_HOST" default:"127.0.0.1"`
-		Port     string `envconfig:"DB_PORT" default:"5432"`
-		Name     string `envconfig:"DB_NAME" default:"mesh_group"`
-		User     string `envconfig:"DB_USER" default:"postgres"`
-		Password string `envconfig:"DB_PASSWORD" default:"postgres"`
-	}
+	Env      string        `env:"ENV" yaml:"env" default:"local"`
+	TokenTTL time.Duration `env:"TOKEN_TTL" yaml:"token_ttl" default:"1h"`
+
+	Log        LogConfig        `yaml:"log"`
+	DB         DBConfig         `yaml:"db"`
+	Connection ConnectionConfig `yaml:"connection"`
+	Import     ImportConfig     `yaml:"import"`
+	Validation ValidationConfig `yaml:"validation"`
+	Webhook    WebhookConfig    `yaml:"webhook"`
+	Outbox     OutboxConfig     `yaml:"outbox"`
+	Cache      CacheConfig      `yaml:"cache"`
+	App        AppConfig        `yaml:"app"`
+}
+
+// LogConfig - настройки логирования
+type LogConfig struct {
+	// Level - уровень логирования; пусто - debug для local/dev, info для prod
+	Level string `env:"LOG_LEVEL" yaml:"level"`
+
+	// Уровни компонентов; пусто - общий уровень
+	SAP        string `env:"LOG_LEVEL_SAP" yaml:"sap"`
+	Repository string `env:"LOG_LEVEL_REPOSITORY" yaml:"repository"`
+	HTTP       string `env:"LOG_LEVEL_HTTP" yaml:"http"`
+
+	// Outputs - выходы логов: stdout, file (файл без ротации), rotating
+	// (файл с ротацией), syslog (UDP)
+	Outputs []string `env:"LOG_OUTPUTS" yaml:"outputs" default:"stdout,rotating"`
+	// Format - формат записей: pretty, json или logfmt; пусто - pretty для
+	// local, json для остальных окружений
+	Format string `env:"LOG_FORMAT" yaml:"format"`
+	// StdoutLevel - минимальный уровень для stdout поверх общего
+	StdoutLevel string `env:"LOG_STDOUT_LEVEL" yaml:"stdout_level"`
+
+	// Файл логов для выходов file и rotating; формат и уровень - пусто
+	// означает LOG_FORMAT и без ограничения
+	File       string `env:"LOG_FILE" yaml:"file" default:"log/segmentation_import.log"`
+	FileFormat string `env:"LOG_FILE_FORMAT" yaml:"file_format"`
+	FileLevel  string `env:"LOG_FILE_LEVEL" yaml:"file_level"`
+
+	// Syslog по UDP (RFC 5424)
+	SyslogAddr   string `env:"LOG_SYSLOG_ADDR" yaml:"syslog_addr" default:"127.0.0.1:514"`
+	SyslogTag    string `env:"LOG_SYSLOG_TAG" yaml:"syslog_tag" default:"sap_segmentationd"`
+	SyslogFormat string `env:"LOG_SYSLOG_FORMAT" yaml:"syslog_format" default:"logfmt"`
+	SyslogLevel  string `env:"LOG_SYSLOG_LEVEL" yaml:"syslog_level"`
+
+	// Сэмплирование debug-записей: за каждый интервал записи с одним
+	// сообщением пропускаются первые SamplingInitial, затем каждая
+	// SamplingThereafter-я. SamplingInitial = 0 выключает сэмплирование.
+	SamplingInitial    int           `env:"LOG_SAMPLING_INITIAL" yaml:"sampling_initial" default:"0"`
+	SamplingThereafter int           `env:"LOG_SAMPLING_THEREAFTER" yaml:"sampling_thereafter" default:"100"`
+	SamplingInterval   time.Duration `env:"LOG_SAMPLING_INTERVAL" yaml:"sampling_interval" default:"1s"`
+
+	// Ротация файла логов
+	MaxSizeMB       int           `env:"LOG_MAX_SIZE_MB" yaml:"max_size_mb" default:"100"`
+	RotateDaily     bool          `env:"LOG_ROTATE_DAILY" yaml:"rotate_daily" default:"true"`
+	MaxBackups      int           `env:"LOG_MAX_BACKUPS" yaml:"max_backups" default:"14"`
+	Compress        bool          `env:"LOG_COMPRESS" yaml:"compress" default:"true"`
+	MaxAgeDays      int           `env:"LOG_CLEANUP_MAX_AGE" yaml:"max_age_days" default:"7"`
+	CleanupInterval time.Duration `env:"LOG_CLEANUP_INTERVAL" yaml:"cleanup_interval" default:"1h"`
+}
 
-	Connection struct {
-		URI          string        `envconfig:"CONN_URI" default:"http://bsm.api.iql.ru/ords/bsm/segmentation/get_segmentation"`
-		AuthLoginPwd string        `envconfig:"CONN_AUTH_LOGIN_PWD" default:"4Dfddf5:jKlljHGH"`
-		UserAgent    string        `envconfig:"CONN_USER_AGENT" default:"spacecount-test"`
-		Timeout      time.Duration `envconfig:"CONN_TIMEOUT" default:"5s"`
-		Interval     time.Duration `envconfig:"CONN_INTERVAL" default:"1500ms"`
+// Components возвращает уровни компонентов по их именам
+func (c LogConfig) Components() map[string]string {
+	return map[string]string{
+		"sap":        c.SAP,
+		"repository": c.Repository,
+		"http":       c.HTTP,
 	}
+}
+
+// DBConfig - подключение к PostgreSQL
+type DBConfig struct {
+	Host     string `env:"DB_HOST" yaml:"host" default:"127.0.0.1"`
+	Port     string `env:"DB_PORT" yaml:"port" default:"5432"`
+	Name     string `env:"DB_NAME" yaml:"name" default:"mesh_group"`
+	User     string `env:"DB_USER" yaml:"user" default:"postgres"`
+	Password Secret `env:"DB_PASSWORD" yaml:"password"`
+}
+
+// ConnectionConfig - подключение к SAP API
+type ConnectionConfig struct {
+	URI          string        `env:"CONN_URI" yaml:"uri" default:"http://bsm.api.iql.ru/ords/bsm/segmentation/get_segmentation"`
+	AuthType     string        `env:"CONN_AUTH_TYPE" yaml:"auth_type" default:"basic"`
+	AuthLoginPwd Secret        `env:"CONN_AUTH_LOGIN_PWD" yaml:"auth_login_pwd"`
+	AuthToken    Secret        `env:"CONN_AUTH_TOKEN" yaml:"auth_token"`
+	UserAgent    string        `env:"CONN_USER_AGENT" yaml:"user_agent" default:"spacecount-test"`
+	Timeout      time.Duration `env:"CONN_TIMEOUT" yaml:"timeout" default:"5s"`
+	Interval     time.Duration `env:"CONN_INTERVAL" yaml:"interval" default:"1500ms"`
+	RateLimit    float64       `env:"CONN_RATE_LIMIT" yaml:"rate_limit" default:"0"`
+	RateBurst    int           `env:"CONN_RATE_BURST" yaml:"rate_burst" default:"1"`
+	Concurrency  int           `env:"CONN_CONCURRENCY" yaml:"concurrency" default:"4"`
+	MaxRetries   int           `env:"CONN_MAX_RETRIES" yaml:"max_retries" default:"3"`
+
+	AdaptiveRate  bool          `env:"CONN_RATE_ADAPTIVE" yaml:"rate_adaptive" default:"true"`
+	RateMin       float64       `env:"CONN_RATE_MIN" yaml:"rate_min" default:"0.2"`
+	RateMax       float64       `env:"CONN_RATE_MAX" yaml:"rate_max" default:"10"`
+	RateIncrease  float64       `env:"CONN_RATE_INCREASE" yaml:"rate_increase" default:"0.1"`
+	RateDecrease  float64       `env:"CONN_RATE_DECREASE" yaml:"rate_decrease" default:"0.5"`
+	LatencyTarget time.Duration `env:"CONN_LATENCY_TARGET" yaml:"latency_target" default:"1s"`
+
+	BreakerEnabled          bool          `env:"CONN_BREAKER_ENABLED" yaml:"breaker_enabled" default:"true"`
+	BreakerFailureRatio     float64       `env:"CONN_BREAKER_FAILURE_RATIO" yaml:"breaker_failure_ratio" default:"0.5"`
+	BreakerMinRequests      int           `env:"CONN_BREAKER_MIN_REQUESTS" yaml:"breaker_min_requests" default:"5"`
+	BreakerWindow           time.Duration `env:"CONN_BREAKER_WINDOW" yaml:"breaker_window" default:"1m"`
+	BreakerCoolDown         time.Duration `env:"CONN_BREAKER_COOLDOWN" yaml:"breaker_cooldown" default:"30s"`
+	BreakerHalfOpenRequests int           `env:"CONN_BREAKER_HALF_OPEN_REQUESTS" yaml:"breaker_half_open_requests" default:"1"`
+
+	ChangedSinceParam string `env:"CONN_CHANGED_SINCE_PARAM" yaml:"changed_since_param" default:"p_changed_since"`
+	Pagination        string `env:"CONN_PAGINATION" yaml:"pagination" default:"offset"`
+	KeysetParam       string `env:"CONN_KEYSET_PARAM" yaml:"keyset_param" default:"p_after"`
+
+	OAuthTokenURL     string   `env:"CONN_OAUTH_TOKEN_URL" yaml:"oauth_token_url"`
+	OAuthClientID     string   `env:"CONN_OAUTH_CLIENT_ID" yaml:"oauth_client_id"`
+	OAuthClientSecret Secret   `env:"CONN_OAUTH_CLIENT_SECRET" yaml:"oauth_client_secret"`
+	OAuthScopes       []string `env:"CONN_OAUTH_SCOPES" yaml:"oauth_scopes"`
+
+	TLSCertFile string `env:"CONN_TLS_CERT_FILE" yaml:"tls_cert_file"`
+	TLSKeyFile  string `env:"CONN_TLS_KEY_FILE" yaml:"tls_key_file"`
+	TLSCAFile   string `env:"CONN_TLS_CA_FILE" yaml:"tls_ca_file"`
+}
 
-	Import struct {
-		BatchSize        int  `envconfig:"IMPORT_BATCH_SIZE" default:"50"`
-		LogCleanupMaxAge int  `envconfig:"LOG_CLEANUP_MAX_AGE" default:"7"`
-		UseTestData      bool `envconfig:"USE_TEST_DATA" default:"true"`
+// ImportConfig - параметры импорта
+type ImportConfig struct {
+	BatchSize   int  `env:"IMPORT_BATCH_SIZE" yaml:"batch_size" default:"50"`
+	DBChunkSize int  `env:"IMPORT_DB_CHUNK_SIZE" yaml:"db_chunk_size" default:"5000"`
+	UseTestData bool `env:"USE_TEST_DATA" yaml:"use_test_data" default:"true"`
+
+	Mode                  string        `env:"IMPORT_MODE" yaml:"mode" default:"full"`
+	FullReconcileInterval time.Duration `env:"IMPORT_FULL_RECONCILE_INTERVAL" yaml:"full_reconcile_interval" default:"24h"`
+}
+
+// ValidationConfig - правила проверки записей SAP
+type ValidationConfig struct {
+	MaxAddressSapIDLength int      `env:"VALIDATION_MAX_SAP_ID_LENGTH" yaml:"max_sap_id_length" default:"255"`
+	MaxAdrSegmentLength   int      `env:"VALIDATION_MAX_SEGMENT_LENGTH" yaml:"max_segment_length" default:"16"`
+	AllowedSegments       []string `env:"VALIDATION_ALLOWED_SEGMENTS" yaml:"allowed_segments"`
+	RejectDuplicates      bool     `env:"VALIDATION_REJECT_DUPLICATES" yaml:"reject_duplicates" default:"true"`
+	// UnknownSegments - что делать с сегментами, которых нет в справочнике:
+	// register - зарегистрировать, reject - отклонить записи
+	UnknownSegments string `env:"VALIDATION_UNKNOWN_SEGMENTS" yaml:"unknown_segments" default:"register"`
+}
+
+// WebhookConfig - уведомления о событиях импорта
+type WebhookConfig struct {
+	// URLs - адреса получателей; пусто - уведомления выключены
+	URLs []string `env:"WEBHOOK_URLS" yaml:"urls"`
+	// Secret - ключ HMAC-подписи; пусто - запросы не подписываются
+	Secret Secret `env:"WEBHOOK_SECRET" yaml:"secret"`
+	// Events - отправляемые события; пусто - все
+	Events []string `env:"WEBHOOK_EVENTS" yaml:"events"`
+
+	Timeout      time.Duration `env:"WEBHOOK_TIMEOUT" yaml:"timeout" default:"10s"`
+	MaxRetries   int           `env:"WEBHOOK_MAX_RETRIES" yaml:"max_retries" default:"5"`
+	RetryBackoff time.Duration `env:"WEBHOOK_RETRY_BACKOFF" yaml:"retry_backoff" default:"1s"`
+	// QueueSize - очередь событий каждого получателя; при переполнении
+	// новые события отбрасываются
+	QueueSize int `env:"WEBHOOK_QUEUE_SIZE" yaml:"queue_size" default:"1000"`
+	// ChangesPerEvent - наибольшее число записей в одном событии segment.changed
+	ChangesPerEvent int `env:"WEBHOOK_CHANGES_PER_EVENT" yaml:"changes_per_event" default:"500"`
+}
+
+// OutboxConfig - публикация изменений сегментации в брокер сообщений
+// через таблицу outbox
+type OutboxConfig struct {
+	// Enabled включает запись изменений в outbox и их публикацию
+	Enabled bool `env:"OUTBOX_ENABLED" yaml:"enabled" default:"false"`
+	// Broker - брокер: nats, kafka, file или memory
+	Broker string `env:"OUTBOX_BROKER" yaml:"broker" default:"file"`
+	// Topic - subject NATS или топик Kafka
+	Topic        string        `env:"OUTBOX_TOPIC" yaml:"topic" default:"segmentation.changed"`
+	PollInterval time.Duration `env:"OUTBOX_POLL_INTERVAL" yaml:"poll_interval" default:"1s"`
+	BatchSize    int           `env:"OUTBOX_BATCH_SIZE" yaml:"batch_size" default:"100"`
+	// Retention - сколько хранить опубликованные записи; 0 - не удалять
+	Retention time.Duration `env:"OUTBOX_RETENTION" yaml:"retention" default:"168h"`
+	// Lease - на сколько экземпляр сервиса забирает пачку событий; пачка,
+	// не опубликованная за это время, может быть забрана другим экземпляром
+	Lease time.Duration `env:"OUTBOX_LEASE" yaml:"lease" default:"1m"`
+
+	// File - файл брокера file (JSON Lines)
+	File string `env:"OUTBOX_FILE" yaml:"file" default:"outbox/segmentation_changes.jsonl"`
+
+	NATSURL      string `env:"OUTBOX_NATS_URL" yaml:"nats_url" default:"nats://127.0.0.1:4222"`
+	NATSUser     string `env:"OUTBOX_NATS_USER" yaml:"nats_user"`
+	NATSPassword Secret `env:"OUTBOX_NATS_PASSWORD" yaml:"nats_password"`
+	NATSToken    Secret `env:"OUTBOX_NATS_TOKEN" yaml:"nats_token"`
+
+	// KafkaURL - адрес Kafka REST Proxy (API v2)
+	KafkaURL      string `env:"OUTBOX_KAFKA_URL" yaml:"kafka_url"`
+	KafkaUser     string `env:"OUTBOX_KAFKA_USER" yaml:"kafka_user"`
+	KafkaPassword Secret `env:"OUTBOX_KAFKA_PASSWORD" yaml:"kafka_password"`
+
+	Timeout time.Duration `env:"OUTBOX_TIMEOUT" yaml:"timeout" default:"10s"`
+}
+
+// CacheConfig - кэш чтения сегментации в памяти процесса
+type CacheConfig struct {
+	Enabled bool `env:"CACHE_ENABLED" yaml:"enabled" default:"false"`
+	// Size - наибольшее число записей LRU-кэша
+	Size int           `env:"CACHE_SIZE" yaml:"size" default:"10000"`
+	TTL  time.Duration `env:"CACHE_TTL" yaml:"ttl" default:"5m"`
+	// Snapshot держит в памяти весь набор данных вместо LRU-кэша
+	Snapshot bool `env:"CACHE_SNAPSHOT" yaml:"snapshot" default:"false"`
+	// SnapshotMaxRows - при большем числе записей используется LRU-кэш
+	SnapshotMaxRows int `env:"CACHE_SNAPSHOT_MAX_ROWS" yaml:"snapshot_max_rows" default:"100000"`
+	// VersionCheckInterval - как часто сверять версию данных в базе, чтобы
+	// увидеть импорт, выполненный другим экземпляром сервиса; 0 - не
+	// сверять, тогда чужие изменения видны только через TTL
+	VersionCheckInterval time.Duration `env:"CACHE_VERSION_CHECK_INTERVAL" yaml:"version_check_interval" default:"10s"`
+}
+
+// AppConfig - настройки HTTP-сервера
+type AppConfig struct {
+	Port string `env:"APP_PORT" yaml:"port" default:"8080"`
+	// AdminToken открывает доступ к /api/admin; пусто - административный API выключен
+	AdminToken Secret `env:"ADMIN_TOKEN" yaml:"admin_token"`
+}
+
+// LogLevel возвращает уровень логирования с учетом окружения
+func (c *Config) LogLevel() slog.Level {
+	var level slog.Level
+	if c.Log.Level != "" && level.UnmarshalText([]byte(c.Log.Level)) == nil {
+		return level
 	}
 
-	App struct {
-		Port string `envconfig:"APP_PORT" default:"8080"`
+	if c.Env == EnvProd {
+		return slog.LevelInfo
 	}
+
+	return slog.LevelDebug
 }
 
-func MustLoad(logger *slog.Logger) *Config {
-	var cfg Config
+// Load собирает конфигурацию из значений по умолчанию, файла CONFIG_FILE
+// (если задан) и переменных окружения и проверяет ее. Возвращаются сразу
+// все найденные ошибки.
+func Load() (*Config, error) {
+	return LoadFile(os.Getenv("CONFIG_FILE"))
+}
+
+// LoadFile работает как Load, но читает указанный файл; пустой путь - без файла
+func LoadFile(path string) (*Config, error) {
+	cfg, err := load(path)
+	if cfg == nil {
+		return nil, err
+	}
 
-	if err := envconfig.Process("", &cfg); err != nil {
-		logger.Error("failed to load config", "error", err.Error())
-		panic("failed to load config: " + err.Error())
+	// Ошибки разбора и проверки значений сообщаются вместе
+	if err := errors.Join(err, cfg.Validate()); err != nil {
+		return nil, err
 	}
 
-	return &cfg
+	return cfg, nil
 }
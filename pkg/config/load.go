@@ -0,0 +1,180 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// load собирает конфигурацию по слоям без проверки значений. Ошибки разбора
+// переменных окружения возвращаются вместе с конфигурацией, чтобы их можно
+// было сообщить одновременно с ошибками проверки.
+func load(path string) (*Config, error) {
+	var cfg Config
+	v := reflect.ValueOf(&cfg).Elem()
+
+	if err := applyDefaults(v); err != nil {
+		return nil, err
+	}
+
+	if path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	return &cfg, applyEnv(v)
+}
+
+// loadFile накладывает значения из YAML-файла. Отсутствующие в файле ключи
+// сохраняют значения по умолчанию, неизвестные ключи считаются ошибкой.
+func loadFile(path string, cfg *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// applyDefaults заполняет поля значениями из тега default
+func applyDefaults(v reflect.Value) error {
+	var errs []error
+
+	walkFields(v, func(field reflect.StructField, value reflect.Value) {
+		def, ok := field.Tag.Lookup("default")
+		if !ok {
+			return
+		}
+
+		if err := setField(value, def); err != nil {
+			errs = append(errs, fmt.Errorf("invalid default for %s: %w", field.Tag.Get("env"), err))
+		}
+	})
+
+	return errors.Join(errs...)
+}
+
+// applyEnv накладывает переменные окружения. Для секретов дополнительно
+// поддерживается вариант <ИМЯ>_FILE с путем к файлу, как при монтировании
+// секретов Docker и Kubernetes; завершающий перевод строки отбрасывается.
+func applyEnv(v reflect.Value) error {
+	var errs []error
+
+	walkFields(v, func(field reflect.StructField, value reflect.Value) {
+		name := field.Tag.Get("env")
+		if name == "" {
+			return
+		}
+
+		raw, ok := os.LookupEnv(name)
+
+		if field.Type == secretType {
+			if path := os.Getenv(name + "_FILE"); path != "" {
+				if ok {
+					errs = append(errs, fmt.Errorf("both %s and %s_FILE are set", name, name))
+					return
+				}
+
+				data, err := os.ReadFile(path)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("failed to read %s_FILE: %w", name, err))
+					return
+				}
+
+				raw, ok = strings.TrimRight(string(data), "\r\n"), true
+			}
+		}
+
+		if !ok {
+			return
+		}
+
+		if err := setField(value, raw); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s: %w", name, err))
+		}
+	})
+
+	return errors.Join(errs...)
+}
+
+// walkFields обходит поля структуры, включая вложенные
+func walkFields(v reflect.Value, fn func(field reflect.StructField, value reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := v.Field(i)
+
+		if field.Type.Kind() == reflect.Struct && field.Type != durationType {
+			walkFields(value, fn)
+			continue
+		}
+
+		fn(field, value)
+	}
+}
+
+// setField разбирает строковое значение в соответствии с типом поля.
+// Списки задаются через запятую.
+func setField(value reflect.Value, raw string) error {
+	if value.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(d))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("expected integer, got %q", raw)
+		}
+		value.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("expected number, got %q", raw)
+		}
+		value.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("expected boolean, got %q", raw)
+		}
+		value.SetBool(b)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", value.Type())
+	}
+
+	return nil
+}
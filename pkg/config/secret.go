@@ -0,0 +1,88 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+)
+
+// redacted выводится вместо значения секрета
+const redacted = "[REDACTED]"
+
+// Secret - строка с учетными данными. При выводе в лог, JSON или через fmt
+// значение скрывается; получить его можно только явно через Value.
+type Secret string
+
+// Value возвращает значение секрета
+func (s Secret) Value() string {
+	return string(s)
+}
+
+// IsSet сообщает, задан ли секрет
+func (s Secret) IsSet() bool {
+	return s != ""
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+func (s Secret) GoString() string {
+	return `config.Secret("` + s.String() + `")`
+}
+
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+var secretType = reflect.TypeOf(Secret(""))
+
+// requireSecrets проверяет, что в prod заданы учетные данные, нужные
+// выбранным способам подключения
+func requireSecrets(cfg *Config) error {
+	if cfg.Env != EnvProd {
+		return nil
+	}
+
+	var missing []string
+	require := func(name string, s Secret) {
+		if !s.IsSet() {
+			missing = append(missing, name)
+		}
+	}
+
+	require("DB_PASSWORD", cfg.DB.Password)
+
+	switch cfg.Connection.AuthType {
+	case "basic", "":
+		require("CONN_AUTH_LOGIN_PWD", cfg.Connection.AuthLoginPwd)
+	case "bearer":
+		require("CONN_AUTH_TOKEN", cfg.Connection.AuthToken)
+	case "oauth2":
+		require("CONN_OAUTH_CLIENT_SECRET", cfg.Connection.OAuthClientSecret)
+	}
+
+	if len(cfg.Webhook.URLs) > 0 {
+		require("WEBHOOK_SECRET", cfg.Webhook.Secret)
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("required secrets are not set: %s (use the variable or its _FILE variant)",
+			strings.Join(missing, ", "))
+	}
+
+	return nil
+}
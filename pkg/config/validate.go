@@ -0,0 +1,176 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strconv"
+)
+
+// Окружения приложения
+const (
+	EnvLocal = "local"
+	EnvDev   = "dev"
+	EnvProd  = "prod"
+)
+
+// Validate проверяет значения конфигурации и возвращает все найденные
+// ошибки одной, объединенной через errors.Join
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	oneOf := func(name, value string, allowed ...string) {
+		check(slices.Contains(allowed, value), "%s must be one of %v, got %q", name, allowed, value)
+	}
+
+	oneOf("ENV", c.Env, EnvLocal, EnvDev, EnvProd)
+	check(c.TokenTTL > 0, "TOKEN_TTL must be positive, got %s", c.TokenTTL)
+
+	checkLevel := func(name, value string) {
+		var level slog.Level
+		check(value == "" || level.UnmarshalText([]byte(value)) == nil,
+			"%s must be debug, info, warn or error, got %q", name, value)
+	}
+	checkLevel("LOG_LEVEL", c.Log.Level)
+	checkLevel("LOG_LEVEL_SAP", c.Log.SAP)
+	checkLevel("LOG_LEVEL_REPOSITORY", c.Log.Repository)
+	checkLevel("LOG_LEVEL_HTTP", c.Log.HTTP)
+	checkLevel("LOG_STDOUT_LEVEL", c.Log.StdoutLevel)
+	checkLevel("LOG_FILE_LEVEL", c.Log.FileLevel)
+	checkLevel("LOG_SYSLOG_LEVEL", c.Log.SyslogLevel)
+
+	check(len(c.Log.Outputs) > 0, "LOG_OUTPUTS must not be empty")
+	for i, output := range c.Log.Outputs {
+		oneOf("LOG_OUTPUTS", output, "stdout", "file", "rotating", "syslog")
+		check(!slices.Contains(c.Log.Outputs[:i], output), "LOG_OUTPUTS contains %q more than once", output)
+	}
+	check(!slices.Contains(c.Log.Outputs, "file") || !slices.Contains(c.Log.Outputs, "rotating"),
+		"LOG_OUTPUTS must not contain both file and rotating")
+	if slices.Contains(c.Log.Outputs, "file") || slices.Contains(c.Log.Outputs, "rotating") {
+		check(c.Log.File != "", "LOG_FILE is required for file output")
+	}
+	if slices.Contains(c.Log.Outputs, "syslog") {
+		check(c.Log.SyslogAddr != "", "LOG_SYSLOG_ADDR is required for syslog output")
+	}
+	checkFormat := func(name, value string) {
+		if value != "" {
+			oneOf(name, value, "pretty", "json", "logfmt")
+		}
+	}
+	checkFormat("LOG_FORMAT", c.Log.Format)
+	checkFormat("LOG_FILE_FORMAT", c.Log.FileFormat)
+	checkFormat("LOG_SYSLOG_FORMAT", c.Log.SyslogFormat)
+	check(c.Log.SamplingInitial >= 0, "LOG_SAMPLING_INITIAL must not be negative, got %d", c.Log.SamplingInitial)
+	if c.Log.SamplingInitial > 0 {
+		check(c.Log.SamplingThereafter > 0, "LOG_SAMPLING_THEREAFTER must be positive, got %d", c.Log.SamplingThereafter)
+		check(c.Log.SamplingInterval > 0, "LOG_SAMPLING_INTERVAL must be positive, got %s", c.Log.SamplingInterval)
+	}
+
+	check(c.Log.MaxSizeMB >= 0, "LOG_MAX_SIZE_MB must not be negative, got %d", c.Log.MaxSizeMB)
+	check(c.Log.MaxBackups >= 0, "LOG_MAX_BACKUPS must not be negative, got %d", c.Log.MaxBackups)
+	check(c.Log.MaxAgeDays >= 0, "LOG_CLEANUP_MAX_AGE must not be negative, got %d", c.Log.MaxAgeDays)
+	check(c.Log.CleanupInterval > 0, "LOG_CLEANUP_INTERVAL must be positive, got %s", c.Log.CleanupInterval)
+
+	check(c.DB.Host != "", "DB_HOST must not be empty")
+	check(validPort(c.DB.Port), "DB_PORT must be a port number, got %q", c.DB.Port)
+	check(c.DB.Name != "", "DB_NAME must not be empty")
+
+	conn := c.Connection
+	check(conn.URI != "", "CONN_URI must not be empty")
+	oneOf("CONN_AUTH_TYPE", conn.AuthType, "none", "basic", "bearer", "oauth2")
+	if conn.AuthType == "oauth2" {
+		check(conn.OAuthTokenURL != "", "CONN_OAUTH_TOKEN_URL is required for oauth2 authentication")
+		check(conn.OAuthClientID != "", "CONN_OAUTH_CLIENT_ID is required for oauth2 authentication")
+	}
+	check(conn.Timeout > 0, "CONN_TIMEOUT must be positive, got %s", conn.Timeout)
+	check(conn.Interval >= 0, "CONN_INTERVAL must not be negative, got %s", conn.Interval)
+	check(conn.RateLimit >= 0, "CONN_RATE_LIMIT must not be negative, got %g", conn.RateLimit)
+	check(conn.RateBurst > 0, "CONN_RATE_BURST must be positive, got %d", conn.RateBurst)
+	check(conn.Concurrency > 0, "CONN_CONCURRENCY must be positive, got %d", conn.Concurrency)
+	check(conn.MaxRetries >= 0, "CONN_MAX_RETRIES must not be negative, got %d", conn.MaxRetries)
+	if conn.AdaptiveRate {
+		check(conn.RateMin > 0, "CONN_RATE_MIN must be positive, got %g", conn.RateMin)
+		check(conn.RateMax >= conn.RateMin, "CONN_RATE_MAX must not be less than CONN_RATE_MIN, got %g", conn.RateMax)
+		check(conn.RateIncrease > 0, "CONN_RATE_INCREASE must be positive, got %g", conn.RateIncrease)
+		check(conn.RateDecrease > 0 && conn.RateDecrease < 1, "CONN_RATE_DECREASE must be between 0 and 1, got %g", conn.RateDecrease)
+	}
+	if conn.BreakerEnabled {
+		check(conn.BreakerFailureRatio > 0 && conn.BreakerFailureRatio <= 1, "CONN_BREAKER_FAILURE_RATIO must be in (0, 1], got %g", conn.BreakerFailureRatio)
+		check(conn.BreakerMinRequests > 0, "CONN_BREAKER_MIN_REQUESTS must be positive, got %d", conn.BreakerMinRequests)
+		check(conn.BreakerWindow > 0, "CONN_BREAKER_WINDOW must be positive, got %s", conn.BreakerWindow)
+		check(conn.BreakerCoolDown > 0, "CONN_BREAKER_COOLDOWN must be positive, got %s", conn.BreakerCoolDown)
+		check(conn.BreakerHalfOpenRequests > 0, "CONN_BREAKER_HALF_OPEN_REQUESTS must be positive, got %d", conn.BreakerHalfOpenRequests)
+	}
+	oneOf("CONN_PAGINATION", conn.Pagination, "offset", "link", "keyset")
+
+	check(c.Import.BatchSize > 0, "IMPORT_BATCH_SIZE must be positive, got %d", c.Import.BatchSize)
+	check(c.Import.DBChunkSize > 0, "IMPORT_DB_CHUNK_SIZE must be positive, got %d", c.Import.DBChunkSize)
+	oneOf("IMPORT_MODE", c.Import.Mode, "full", "incremental")
+	check(c.Import.FullReconcileInterval >= 0, "IMPORT_FULL_RECONCILE_INTERVAL must not be negative, got %s", c.Import.FullReconcileInterval)
+
+	check(c.Validation.MaxAddressSapIDLength > 0, "VALIDATION_MAX_SAP_ID_LENGTH must be positive, got %d", c.Validation.MaxAddressSapIDLength)
+	check(c.Validation.MaxAdrSegmentLength > 0, "VALIDATION_MAX_SEGMENT_LENGTH must be positive, got %d", c.Validation.MaxAdrSegmentLength)
+	oneOf("VALIDATION_UNKNOWN_SEGMENTS", c.Validation.UnknownSegments, "register", "reject")
+
+	hook := c.Webhook
+	for _, raw := range hook.URLs {
+		u, err := url.Parse(raw)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"WEBHOOK_URLS must contain http(s) URLs, got %q", raw)
+	}
+	for _, event := range hook.Events {
+		oneOf("WEBHOOK_EVENTS", event, "import.succeeded", "import.failed", "segment.changed")
+	}
+	check(hook.Timeout > 0, "WEBHOOK_TIMEOUT must be positive, got %s", hook.Timeout)
+	check(hook.MaxRetries >= 0, "WEBHOOK_MAX_RETRIES must not be negative, got %d", hook.MaxRetries)
+	check(hook.RetryBackoff > 0, "WEBHOOK_RETRY_BACKOFF must be positive, got %s", hook.RetryBackoff)
+	check(hook.QueueSize > 0, "WEBHOOK_QUEUE_SIZE must be positive, got %d", hook.QueueSize)
+	check(hook.ChangesPerEvent > 0, "WEBHOOK_CHANGES_PER_EVENT must be positive, got %d", hook.ChangesPerEvent)
+
+	if ob := c.Outbox; ob.Enabled {
+		oneOf("OUTBOX_BROKER", ob.Broker, "nats", "kafka", "file", "memory")
+		check(ob.Topic != "", "OUTBOX_TOPIC must not be empty")
+		check(ob.PollInterval > 0, "OUTBOX_POLL_INTERVAL must be positive, got %s", ob.PollInterval)
+		check(ob.BatchSize > 0, "OUTBOX_BATCH_SIZE must be positive, got %d", ob.BatchSize)
+		check(ob.Retention >= 0, "OUTBOX_RETENTION must not be negative, got %s", ob.Retention)
+		check(ob.Timeout > 0, "OUTBOX_TIMEOUT must be positive, got %s", ob.Timeout)
+		check(ob.Lease >= ob.Timeout, "OUTBOX_LEASE must not be less than OUTBOX_TIMEOUT, got %s", ob.Lease)
+		switch ob.Broker {
+		case "file":
+			check(ob.File != "", "OUTBOX_FILE is required for file broker")
+		case "nats":
+			u, err := url.Parse(ob.NATSURL)
+			check(err == nil && u.Scheme == "nats" && u.Host != "", "OUTBOX_NATS_URL must be a nats:// URL, got %q", ob.NATSURL)
+		case "kafka":
+			u, err := url.Parse(ob.KafkaURL)
+			check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+				"OUTBOX_KAFKA_URL must be an http(s) URL, got %q", ob.KafkaURL)
+		}
+	}
+
+	if cache := c.Cache; cache.Enabled {
+		check(cache.Size > 0, "CACHE_SIZE must be positive, got %d", cache.Size)
+		check(cache.TTL > 0, "CACHE_TTL must be positive, got %s", cache.TTL)
+		check(cache.SnapshotMaxRows > 0, "CACHE_SNAPSHOT_MAX_ROWS must be positive, got %d", cache.SnapshotMaxRows)
+		check(cache.VersionCheckInterval >= 0, "CACHE_VERSION_CHECK_INTERVAL must not be negative, got %s", cache.VersionCheckInterval)
+	}
+
+	check(validPort(c.App.Port), "APP_PORT must be a port number, got %q", c.App.Port)
+
+	if err := requireSecrets(c); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
@@ -1,46 +0,0 @@
-package logger
-
-import (
-	"log/slog"
-	"os"
-
-	"go-test/pkg/logger/slogpretty"
-)
-
-type Logger interface {
-	Info(msg string, args ...any)
-	Error(msg string, args ...any)
-	Debug(msg string, args ...any)
-	Warn(msg string, args ...any)
-	Fatal(msg string, args ...any)
-}
-
-func SetupLogger(env string) *slog.Logger {
-	var logger *slog.Logger
-
-	switch env {
-	case "local":
-		logger = setupPrettyLogger()
-	case "dev":
-		logger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-			Level: slog.LevelDebug,
-		}))
-	case "prod":
-		logger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-			Level: slog.LevelInfo,
-		}))
-	}
-	return logger
-}
-
-func setupPrettyLogger() *slog.Logger {
-	opts := slogpretty.PrettyHandlerOptions{
-		SlogOpts: &slog.HandlerOptions{
-			Level: slog.LevelDebug,
-		},
-	}
-
-	handler := opts.NewPrettyHandler(os.Stdout)
-
-	return slog.New(handler)
-}
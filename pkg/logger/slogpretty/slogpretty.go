@@ -1,23 +1,53 @@
 package slogpretty
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
-	stdLog "log"
 	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
 )
 
+// timeFormat - формат времени в начале строки
+const timeFormat = "[15:04:05.000]"
+
 type PrettyHandlerOptions struct {
 	SlogOpts *slog.HandlerOptions
+	// NoColor отключает цвет. Без него цвет включается, только если вывод -
+	// терминал.
+	NoColor bool
 }
 
+// PrettyHandler выводит записи в удобном для чтения виде: время, уровень
+// и сообщение в одной строке, атрибуты - JSON-объектом с отсортированными
+// ключами. Группы становятся вложенными объектами.
 type PrettyHandler struct {
-	opts PrettyHandlerOptions
-	slog.Handler
-	l     *stdLog.Logger
+	opts  slog.HandlerOptions
+	color bool
+
+	// goas - накопленные через WithGroup/WithAttrs группы и атрибуты в порядке вызовов
+	goas []groupOrAttrs
+
+	mu  *sync.Mutex
+	out io.Writer
+}
+
+// group - атрибуты группы в выводе
+type group map[string]any
+
+// groupOrAttrs - либо открытая группа, либо атрибуты
+type groupOrAttrs struct {
+	group string
 	attrs []slog.Attr
 }
 
@@ -25,74 +55,253 @@ func (opts PrettyHandlerOptions) NewPrettyHandler(
 	out io.Writer,
 ) *PrettyHandler {
 	h := &PrettyHandler{
-		Handler: slog.NewJSONHandler(out, opts.SlogOpts),
-		l:       stdLog.New(out, "", 0),
+		color: !opts.NoColor && isTerminal(out),
+		mu:    &sync.Mutex{},
+		out:   out,
+	}
+	if opts.SlogOpts != nil {
+		h.opts = *opts.SlogOpts
 	}
 
 	return h
 }
 
+func isTerminal(out io.Writer) bool {
+	f, ok := out.(*os.File)
+	if !ok {
+		return false
+	}
+
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+func (h *PrettyHandler) Enabled(_ context.Context, level slog.Level) bool {
+	minLevel := slog.LevelInfo
+	if h.opts.Level != nil {
+		minLevel = h.opts.Level.Level()
+	}
+
+	return level >= minLevel
+}
+
+func (h *PrettyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	return h.withGroupOrAttrs(groupOrAttrs{attrs: attrs})
+}
+
+func (h *PrettyHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	return h.withGroupOrAttrs(groupOrAttrs{group: name})
+}
+
+func (h *PrettyHandler) withGroupOrAttrs(goa groupOrAttrs) *PrettyHandler {
+	h2 := *h
+	h2.goas = append(slices.Clip(h.goas), goa)
+
+	return &h2
+}
+
 func (h *PrettyHandler) Handle(_ context.Context, r slog.Record) error {
-	level := r.Level.String() + ":"
+	var header []string
 
-	switch r.Level {
-	case slog.LevelDebug:
-		level = color.MagentaString(level)
-	case slog.LevelInfo:
-		level = color.BlueString(level)
-	case slog.LevelWarn:
-		level = color.YellowString(level)
-	case slog.LevelError:
-		level = color.RedString(level)
+	if !r.Time.IsZero() {
+		if a, ok := h.builtin(slog.Time(slog.TimeKey, r.Time)); ok {
+			if a.Value.Kind() == slog.KindTime {
+				header = append(header, a.Value.Time().Format(timeFormat))
+			} else {
+				header = append(header, a.Value.String())
+			}
+		}
 	}
 
-	fields := make(map[string]interface{}, r.NumAttrs())
+	if a, ok := h.builtin(slog.Any(slog.LevelKey, r.Level)); ok {
+		header = append(header, h.colorLevel(r.Level, a.Value.String()+":"))
+	}
 
-	r.Attrs(func(a slog.Attr) bool {
-		fields[a.Key] = a.Value.Any()
+	if a, ok := h.builtin(slog.String(slog.MessageKey, r.Message)); ok {
+		header = append(header, h.paint(color.FgCyan, a.Value.String()))
+	}
+
+	fields := make(group)
+
+	if h.opts.AddSource && r.PC != 0 {
+		frames := runtime.CallersFrames([]uintptr{r.PC})
+		frame, _ := frames.Next()
+		h.addAttr(fields, nil, slog.Any(slog.SourceKey, &slog.Source{
+			Function: frame.Function,
+			File:     frame.File,
+			Line:     frame.Line,
+		}))
+	}
+
+	// Атрибуты из WithAttrs попадают в группы, открытые до них
+	current := fields
+	var groups []string
+	for _, goa := range h.goas {
+		if goa.group != "" {
+			sub := make(group)
+			current[goa.group] = sub
+			current = sub
+			groups = append(groups, goa.group)
+			continue
+		}
+		for _, a := range goa.attrs {
+			h.addAttr(current, groups, a)
+		}
+	}
 
+	r.Attrs(func(a slog.Attr) bool {
+		h.addAttr(current, groups, a)
 		return true
 	})
 
-	for _, a := range h.attrs {
-		fields[a.Key] = a.Value.Any()
-	}
+	pruneEmpty(fields)
 
-	var b []byte
-	var err error
+	var buf bytes.Buffer
+	for i, part := range header {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(part)
+	}
 
 	if len(fields) > 0 {
-		b, err = json.MarshalIndent(fields, "", "  ")
+		// encoding/json сортирует ключи объектов, поэтому вывод детерминирован
+		b, err := json.MarshalIndent(fields, "", "  ")
 		if err != nil {
 			return err
 		}
+		buf.WriteByte(' ')
+		buf.WriteString(h.paint(color.FgWhite, string(b)))
 	}
+	buf.WriteByte('\n')
 
-	timeStr := r.Time.Format("[15:05:05.000]")
-	msg := color.CyanString(r.Message)
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
-	h.l.Println(
-		timeStr,
-		level,
-		msg,
-		color.WhiteString(string(b)),
-	)
+	_, err := h.out.Write(buf.Bytes())
+	return err
+}
+
+// builtin применяет ReplaceAttr к встроенному атрибуту. false - атрибут удален.
+func (h *PrettyHandler) builtin(a slog.Attr) (slog.Attr, bool) {
+	if h.opts.ReplaceAttr != nil {
+		a = h.opts.ReplaceAttr(nil, a)
+		a.Value = a.Value.Resolve()
+	}
 
-	return nil
+	return a, !a.Equal(slog.Attr{})
 }
 
-func (h *PrettyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	return &PrettyHandler{
-		Handler: h.Handler,
-		l:       h.l,
-		attrs:   attrs,
+// addAttr добавляет атрибут в m. Пустые атрибуты и группы пропускаются,
+// группы с пустым ключом раскрываются на текущем уровне.
+func (h *PrettyHandler) addAttr(m group, groups []string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+
+	if h.opts.ReplaceAttr != nil && a.Value.Kind() != slog.KindGroup {
+		a = h.opts.ReplaceAttr(groups, a)
+		a.Value = a.Value.Resolve()
+	}
+
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	if a.Value.Kind() != slog.KindGroup {
+		m[a.Key] = jsonValue(a.Value)
+		return
+	}
+
+	attrs := a.Value.Group()
+	if len(attrs) == 0 {
+		return
+	}
+
+	if a.Key == "" {
+		for _, ga := range attrs {
+			h.addAttr(m, groups, ga)
+		}
+		return
+	}
+
+	sub, ok := m[a.Key].(group)
+	if !ok {
+		sub = make(group)
+	}
+	for _, ga := range attrs {
+		h.addAttr(sub, append(slices.Clip(groups), a.Key), ga)
+	}
+	if len(sub) > 0 {
+		m[a.Key] = sub
 	}
 }
 
-func (h *PrettyHandler) WithGroup(name string) slog.Handler {
-	// TODO: implement
-	return &PrettyHandler{
-		Handler: h.Handler.WithGroup(name),
-		l:       h.l,
+// jsonValue приводит значение атрибута к виду, пригодному для encoding/json
+func jsonValue(v slog.Value) any {
+	switch v.Kind() {
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case *slog.Source:
+			return fmt.Sprintf("%s:%d", filepath.Join(filepath.Base(filepath.Dir(x.File)), filepath.Base(x.File)), x.Line)
+		case error:
+			return x.Error()
+		case json.Marshaler:
+			return x
+		default:
+			if _, err := json.Marshal(x); err != nil {
+				return fmt.Sprintf("%+v", x)
+			}
+			return x
+		}
+	default:
+		return v.Any()
 	}
 }
+
+// pruneEmpty удаляет группы, в которые не попало ни одного атрибута
+func pruneEmpty(m group) {
+	for k, v := range m {
+		sub, ok := v.(group)
+		if !ok {
+			continue
+		}
+		pruneEmpty(sub)
+		if len(sub) == 0 {
+			delete(m, k)
+		}
+	}
+}
+
+func (h *PrettyHandler) colorLevel(level slog.Level, s string) string {
+	switch {
+	case level >= slog.LevelError:
+		return h.paint(color.FgRed, s)
+	case level >= slog.LevelWarn:
+		return h.paint(color.FgYellow, s)
+	case level >= slog.LevelInfo:
+		return h.paint(color.FgBlue, s)
+	default:
+		return h.paint(color.FgMagenta, s)
+	}
+}
+
+func (h *PrettyHandler) paint(attr color.Attribute, s string) string {
+	if !h.color {
+		return s
+	}
+
+	c := color.New(attr)
+	c.EnableColor()
+
+	return c.Sprint(s)
+}
@@ -0,0 +1,135 @@
+package slogpretty
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/slogtest"
+)
+
+func TestPrettyHandlerConformance(t *testing.T) {
+	var buf bytes.Buffer
+
+	newHandler := func(t *testing.T) slog.Handler {
+		buf.Reset()
+		return PrettyHandlerOptions{NoColor: true}.NewPrettyHandler(&buf)
+	}
+
+	result := func(t *testing.T) map[string]any {
+		records := parseRecords(t, buf.String())
+		if len(records) != 1 {
+			t.Fatalf("expected 1 record, got %d:\n%s", len(records), buf.String())
+		}
+		return records[0]
+	}
+
+	slogtest.Run(t, newHandler, result)
+}
+
+func TestPrettyHandlerReplaceAttr(t *testing.T) {
+	var buf bytes.Buffer
+	var paths []string
+
+	h := PrettyHandlerOptions{
+		NoColor: true,
+		SlogOpts: &slog.HandlerOptions{
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				switch {
+				case len(groups) == 0 && a.Key == slog.TimeKey:
+					return slog.Attr{}
+				case a.Key == "secret":
+					return slog.String(a.Key, "***")
+				case a.Key == "drop":
+					return slog.Attr{}
+				}
+				if a.Key == "b" {
+					paths = append(paths, strings.Join(groups, "."))
+				}
+				return a
+			},
+		},
+	}.NewPrettyHandler(&buf)
+
+	slog.New(h).With("secret", "token").WithGroup("g").With("drop", 1).
+		Info("message", slog.Group("h", "b", 2), "secret", "x")
+
+	records := parseRecords(t, buf.String())
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d:\n%s", len(records), buf.String())
+	}
+	got := records[0]
+
+	if _, ok := got[slog.TimeKey]; ok {
+		t.Errorf("time should be removed by ReplaceAttr: %v", got)
+	}
+	if got["secret"] != "***" {
+		t.Errorf("top-level secret = %v, want ***", got["secret"])
+	}
+
+	g, ok := got["g"].(map[string]any)
+	if !ok {
+		t.Fatalf("group g is missing: %v", got)
+	}
+	if _, ok := g["drop"]; ok {
+		t.Errorf("drop should be removed by ReplaceAttr: %v", g)
+	}
+	if g["secret"] != "***" {
+		t.Errorf("g.secret = %v, want ***", g["secret"])
+	}
+	if h, ok := g["h"].(map[string]any); !ok || h["b"] != float64(2) {
+		t.Errorf("g.h.b is missing: %v", g)
+	}
+	if len(paths) != 1 || paths[0] != "g.h" {
+		t.Errorf("ReplaceAttr groups for b = %v, want [g.h]", paths)
+	}
+}
+
+// parseRecords разбирает вывод PrettyHandler без цвета: строка заголовка
+// "[время] УРОВЕНЬ: сообщение", за которой может следовать JSON-объект
+// атрибутов, закрывающийся "}" в начале строки
+func parseRecords(t *testing.T, out string) []map[string]any {
+	t.Helper()
+
+	var records []map[string]any
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		record := make(map[string]any)
+
+		header := line
+		if before, ok := strings.CutSuffix(line, " {"); ok {
+			header = before
+			body := "{"
+			for !strings.HasPrefix(lines[i], "}") {
+				i++
+				if i >= len(lines) {
+					t.Fatalf("unterminated attributes:\n%s", out)
+				}
+				body += "\n" + lines[i]
+			}
+			if err := json.Unmarshal([]byte(body), &record); err != nil {
+				t.Fatalf("failed to parse attributes %q: %v", body, err)
+			}
+		}
+
+		if strings.HasPrefix(header, "[") {
+			ts, rest, _ := strings.Cut(header, " ")
+			record[slog.TimeKey] = strings.Trim(ts, "[]")
+			header = rest
+		}
+
+		level, msg, _ := strings.Cut(header, ": ")
+		if strings.HasSuffix(header, ":") && msg == "" {
+			level = strings.TrimSuffix(header, ":")
+		}
+		record[slog.LevelKey] = level
+		record[slog.MessageKey] = msg
+
+		records = append(records, record)
+	}
+
+	return records
+}
@@ -32,8 +32,18 @@ fi
 # Проверка наличия необходимых переменных окружения
 env_file="./compose/.env"
 if [ ! -f "$env_file" ]; then
-    echo "Файл .env не найден в директории compose. Создайте его на основе примера."
+    echo "Файл .env не найден в директории compose. Создайте его на основе примера:"
+    echo "cp compose/.env.example compose/.env"
     exit 1
 fi
 
+# Проверка файлов с секретами
+for secret in db_password conn_auth_login_pwd; do
+    if [ ! -f "./compose/secrets/$secret" ]; then
+        echo "Файл секрета compose/secrets/$secret не найден. Создайте его, например:"
+        echo "mkdir -p compose/secrets && printf '%s' '<значение>' > compose/secrets/$secret"
+        exit 1
+    fi
+done
+
 echo "✅ Все необходимые зависимости установлены." 
\ No newline at end of file
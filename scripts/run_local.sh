@@ -13,6 +13,18 @@ fi
 # Создаем директорию для логов, если она не существует
 mkdir -p log
 
+# Проверяем конфигурацию и секреты
+if [ ! -f compose/.env ]; then
+    echo "Файл compose/.env не найден. Создайте его на основе примера: cp compose/.env.example compose/.env"
+    exit 1
+fi
+for secret in db_password conn_auth_login_pwd; do
+    if [ ! -f "compose/secrets/$secret" ]; then
+        echo "Файл секрета compose/secrets/$secret не найден."
+        exit 1
+    fi
+done
+
 # Проверяем, запущен ли PostgreSQL
 source compose/.env
 
@@ -34,12 +46,13 @@ DB_HOST="$DB_HOST" \
 DB_PORT="$DB_PORT" \
 DB_NAME="$DB_NAME" \
 DB_USER="$DB_USER" \
-DB_PASSWORD="$DB_PASSWORD" \
+DB_PASSWORD_FILE="$PWD/compose/secrets/db_password" \
 CONN_URI="$CONN_URI" \
-CONN_AUTH_LOGIN_PWD="$CONN_AUTH_LOGIN_PWD" \
+CONN_AUTH_LOGIN_PWD_FILE="$PWD/compose/secrets/conn_auth_login_pwd" \
 CONN_USER_AGENT="$CONN_USER_AGENT" \
 CONN_TIMEOUT="$CONN_TIMEOUT" \
 CONN_INTERVAL="$CONN_INTERVAL" \
 IMPORT_BATCH_SIZE="$IMPORT_BATCH_SIZE" \
 LOG_CLEANUP_MAX_AGE="$LOG_CLEANUP_MAX_AGE" \
+USE_TEST_DATA="$USE_TEST_DATA" \
 ./bin/sap_segmentationd 
\ No newline at end of file
@@ -3,9 +3,15 @@ CREATE TABLE IF NOT EXISTS segmentation (
     address_sap_id VARCHAR(255) NOT NULL,
     adr_segment VARCHAR(16) NOT NULL,
     segment_id BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
     CONSTRAINT unique_address_sap_id UNIQUE (address_sap_id)
 );
 
+-- Добавление служебных колонок в уже существующую таблицу
+ALTER TABLE segmentation ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE segmentation ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
 -- Создание индекса для быстрого поиска по address_sap_id
 CREATE INDEX IF NOT EXISTS idx_segmentation_address_sap_id ON segmentation (address_sap_id);
 
@@ -14,4 +20,159 @@ COMMENT ON TABLE segmentation IS 'Таблица для хранения дан
 COMMENT ON COLUMN segmentation.id IS 'Автоинкрементируемое уникальное поле';
 COMMENT ON COLUMN segmentation.address_sap_id IS 'Идентификатор адреса в SAP';
 COMMENT ON COLUMN segmentation.adr_segment IS 'Сегмент адреса';
-COMMENT ON COLUMN segmentation.segment_id IS 'Идентификатор сегмента'; 
\ No newline at end of file
+COMMENT ON COLUMN segmentation.segment_id IS 'Идентификатор сегмента';
+COMMENT ON COLUMN segmentation.created_at IS 'Время первой загрузки записи';
+COMMENT ON COLUMN segmentation.updated_at IS 'Время последнего изменения сегмента'; 
+
+-- Справочник сегментов
+CREATE TABLE IF NOT EXISTS segments (
+    id BIGINT PRIMARY KEY,
+    code VARCHAR(16) NOT NULL,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT unique_segments_code UNIQUE (code),
+    CONSTRAINT unique_segments_id_code UNIQUE (id, code)
+);
+
+COMMENT ON TABLE segments IS 'Справочник сегментов';
+COMMENT ON COLUMN segments.id IS 'Идентификатор сегмента (segment_id в SAP)';
+COMMENT ON COLUMN segments.code IS 'Код сегмента (adr_segment в SAP)';
+COMMENT ON COLUMN segments.name IS 'Отображаемое название';
+COMMENT ON COLUMN segments.active IS 'Сегмент можно назначать при импорте';
+
+-- Регистрация сегментов, уже присутствующих в данных
+INSERT INTO segments (id, code, name)
+SELECT DISTINCT ON (segment_id) segment_id, adr_segment, adr_segment
+FROM segmentation
+ORDER BY segment_id, updated_at DESC
+ON CONFLICT DO NOTHING;
+
+-- Пара segment_id и adr_segment должна быть зарегистрирована в справочнике.
+-- NOT VALID: существующие противоречивые записи не мешают установке;
+-- после их исправления выполните
+-- ALTER TABLE segmentation VALIDATE CONSTRAINT fk_segmentation_segment
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_segmentation_segment') THEN
+        ALTER TABLE segmentation
+            ADD CONSTRAINT fk_segmentation_segment FOREIGN KEY (segment_id, adr_segment)
+            REFERENCES segments (id, code) NOT VALID;
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_segmentation_segment ON segmentation (segment_id, adr_segment);
+
+-- История запусков импорта
+CREATE TABLE IF NOT EXISTS imports (
+    id BIGSERIAL PRIMARY KEY,
+    status VARCHAR(16) NOT NULL,
+    mode VARCHAR(16) NOT NULL DEFAULT 'full',
+    since TIMESTAMPTZ,
+    high_water_mark TIMESTAMPTZ,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    finished_at TIMESTAMPTZ,
+    fetched INTEGER NOT NULL DEFAULT 0,
+    rejected INTEGER NOT NULL DEFAULT 0,
+    inserted INTEGER NOT NULL DEFAULT 0,
+    updated INTEGER NOT NULL DEFAULT 0,
+    unchanged INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    request_id VARCHAR(128)
+);
+
+ALTER TABLE imports ADD COLUMN IF NOT EXISTS mode VARCHAR(16) NOT NULL DEFAULT 'full';
+ALTER TABLE imports ADD COLUMN IF NOT EXISTS since TIMESTAMPTZ;
+ALTER TABLE imports ADD COLUMN IF NOT EXISTS high_water_mark TIMESTAMPTZ;
+ALTER TABLE imports ADD COLUMN IF NOT EXISTS request_id VARCHAR(128);
+
+COMMENT ON TABLE imports IS 'История запусков импорта сегментации';
+COMMENT ON COLUMN imports.status IS 'Статус импорта: running, succeeded, failed';
+COMMENT ON COLUMN imports.fetched IS 'Количество записей, полученных из SAP';
+COMMENT ON COLUMN imports.rejected IS 'Количество записей, отклоненных валидацией';
+COMMENT ON COLUMN imports.mode IS 'Режим импорта: full или incremental';
+COMMENT ON COLUMN imports.since IS 'Нижняя граница времени изменения для инкрементального импорта';
+COMMENT ON COLUMN imports.high_water_mark IS 'Максимальное время изменения в SAP среди загруженных записей';
+COMMENT ON COLUMN imports.request_id IS 'Идентификатор HTTP-запроса (X-Request-ID), запустившего импорт';
+
+-- Распределение по сегментам после каждого успешного импорта
+CREATE TABLE IF NOT EXISTS import_segment_counts (
+    import_id BIGINT NOT NULL REFERENCES imports (id) ON DELETE CASCADE,
+    prefix TEXT NOT NULL DEFAULT '',
+    adr_segment VARCHAR(16) NOT NULL,
+    segment_id BIGINT NOT NULL,
+    count BIGINT NOT NULL,
+    PRIMARY KEY (import_id, prefix, segment_id, adr_segment)
+);
+
+COMMENT ON TABLE import_segment_counts IS 'Количество адресов по сегментам после успешного импорта';
+COMMENT ON COLUMN import_segment_counts.prefix IS 'Префикс address_sap_id (SAP-, SEG-, ADR-); пусто - без префикса';
+
+-- Карантин для записей, не прошедших валидацию
+CREATE TABLE IF NOT EXISTS segmentation_rejects (
+    id BIGSERIAL PRIMARY KEY,
+    import_id BIGINT NOT NULL REFERENCES imports (id) ON DELETE CASCADE,
+    address_sap_id TEXT NOT NULL,
+    adr_segment TEXT NOT NULL,
+    segment_id BIGINT NOT NULL,
+    reason VARCHAR(64) NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_segmentation_rejects_import_id ON segmentation_rejects (import_id);
+
+COMMENT ON TABLE segmentation_rejects IS 'Записи из SAP, отклоненные при валидации';
+COMMENT ON COLUMN segmentation_rejects.reason IS 'Код причины отклонения';
+COMMENT ON COLUMN segmentation_rejects.details IS 'Описание причины отклонения';
+
+-- Журнал доставки уведомлений (webhooks)
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    event_id VARCHAR(64) NOT NULL,
+    event_type VARCHAR(32) NOT NULL,
+    url TEXT NOT NULL,
+    attempt INTEGER NOT NULL,
+    status_code INTEGER,
+    error TEXT NOT NULL DEFAULT '',
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    succeeded BOOLEAN NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_event_id ON webhook_deliveries (event_id);
+
+COMMENT ON TABLE webhook_deliveries IS 'Попытки доставки уведомлений о событиях импорта';
+COMMENT ON COLUMN webhook_deliveries.event_id IS 'Идентификатор события (X-Webhook-ID)';
+COMMENT ON COLUMN webhook_deliveries.event_type IS 'Тип события: import.succeeded, import.failed, segment.changed';
+COMMENT ON COLUMN webhook_deliveries.attempt IS 'Номер попытки, начиная с 1';
+COMMENT ON COLUMN webhook_deliveries.status_code IS 'HTTP-статус ответа получателя; NULL - ответ не получен';
+COMMENT ON COLUMN webhook_deliveries.succeeded IS 'Получатель ответил 2xx';
+
+-- Outbox: изменения сегментации для публикации в брокер сообщений
+CREATE TABLE IF NOT EXISTS segmentation_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    address_sap_id VARCHAR(255) NOT NULL,
+    event_type VARCHAR(32) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    published_at TIMESTAMPTZ,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    claimed_until TIMESTAMPTZ
+);
+
+ALTER TABLE segmentation_outbox ADD COLUMN IF NOT EXISTS claimed_until TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_segmentation_outbox_pending ON segmentation_outbox (id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_segmentation_outbox_pending_key ON segmentation_outbox (address_sap_id, id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_segmentation_outbox_published_at ON segmentation_outbox (published_at) WHERE published_at IS NOT NULL;
+
+COMMENT ON TABLE segmentation_outbox IS 'Изменения сегментации, записанные в транзакции импорта, для публикации в брокер';
+COMMENT ON COLUMN segmentation_outbox.payload IS 'Новые и прежние значения записи';
+COMMENT ON COLUMN segmentation_outbox.published_at IS 'Время публикации; NULL - ожидает публикации';
+COMMENT ON COLUMN segmentation_outbox.attempts IS 'Количество неудачных попыток публикации';
+COMMENT ON COLUMN segmentation_outbox.last_error IS 'Ошибка последней неудачной попытки';
+COMMENT ON COLUMN segmentation_outbox.claimed_until IS 'До какого времени событие публикуется экземпляром сервиса, забравшим его';